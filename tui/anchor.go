@@ -0,0 +1,210 @@
+package main
+
+import "strings"
+
+// maxAnchorNeighbours is how many rows on each side of the cursor are
+// remembered as fallbacks when the cursor's own row disappears.
+const maxAnchorNeighbours = 5
+
+// anchor identifies a row across reloads independently of its position:
+// sections by their heading path, items by parent path, title and how many
+// items with the same title precede them in that section.
+type anchor struct {
+	isSection  bool
+	path       string
+	title      string
+	occurrence int
+}
+
+// sectionRef describes a section's place in the tree for matching sections
+// between two snapshots.
+type sectionRef struct {
+	key    string
+	parent string
+	level  int
+	index  int    // position among its siblings
+	items  string // item titles, used to recognise a renamed section
+}
+
+// nodeAnchors returns the anchor of every row in nodes.
+func nodeAnchors(nodes []node) []anchor {
+	anchors := make([]anchor, len(nodes))
+	seen := make(map[anchor]int)
+	for i, n := range nodes {
+		a := anchor{isSection: n.isSection, path: n.key}
+		if !n.isSection {
+			a.title = n.item.Title
+		}
+		count := seen[a]
+		seen[a]++
+		a.occurrence = count
+		anchors[i] = a
+	}
+	return anchors
+}
+
+// cursorAnchors returns the cursor row's anchor followed by its neighbours,
+// nearest first and the following row before the preceding one.
+func (m model) cursorAnchors() []anchor {
+	if m.cursor >= len(m.nodes) {
+		return nil
+	}
+	all := nodeAnchors(m.nodes)
+	anchors := []anchor{all[m.cursor]}
+	for d := 1; d <= maxAnchorNeighbours; d++ {
+		if i := m.cursor + d; i < len(all) {
+			anchors = append(anchors, all[i])
+		}
+		if i := m.cursor - d; i >= 0 {
+			anchors = append(anchors, all[i])
+		}
+	}
+	return anchors
+}
+
+// restoreCursor moves the cursor to the first of anchors still present,
+// following renamed section paths. If none survive the cursor keeps its index.
+func (m *model) restoreCursor(anchors []anchor, renames map[string]string) {
+	if len(anchors) == 0 {
+		return
+	}
+	index := make(map[anchor]int)
+	for i, a := range nodeAnchors(m.nodes) {
+		index[a] = i
+	}
+	for _, a := range anchors {
+		a.path = renamePath(a.path, renames)
+		if i, ok := index[a]; ok {
+			m.cursor = i
+			m.ensureVisible()
+			return
+		}
+	}
+}
+
+// sectionRefs lists every section of the tree in order.
+func sectionRefs(sections []TodoSection, prefix string) []sectionRef {
+	var refs []sectionRef
+	for i, s := range sections {
+		key := sectionKey(prefix, s.Heading)
+		titles := make([]string, len(s.Items))
+		for j, item := range s.Items {
+			titles[j] = item.Title
+		}
+		refs = append(refs, sectionRef{
+			key:    key,
+			parent: prefix,
+			level:  s.Level,
+			index:  i,
+			items:  strings.Join(titles, "\n"),
+		})
+		refs = append(refs, sectionRefs(s.Subsections, key)...)
+	}
+	return refs
+}
+
+// matchSections maps the keys of old sections to the keys of the same
+// sections in the new tree. Sections keep their key when it still exists.
+// Otherwise a section counts as renamed when a new, unmatched section under
+// the same (possibly renamed) parent at the same level has the same items, or
+// failing that sits at the same sibling position. Only changed keys are
+// returned.
+func matchSections(old, next []sectionRef) map[string]string {
+	newKeys := make(map[string]bool, len(next))
+	for _, n := range next {
+		newKeys[n.key] = true
+	}
+	oldKeys := make(map[string]bool, len(old))
+	for _, o := range old {
+		oldKeys[o.key] = true
+	}
+
+	mapping := make(map[string]string)
+	used := make(map[string]bool)
+	for _, o := range old {
+		if newKeys[o.key] {
+			mapping[o.key] = o.key
+			used[o.key] = true
+		}
+	}
+
+	for _, o := range old {
+		if _, ok := mapping[o.key]; ok {
+			continue
+		}
+		parent := o.parent
+		if parent != "" {
+			p, ok := mapping[parent]
+			if !ok {
+				continue
+			}
+			parent = p
+		}
+
+		var byItems, byIndex string
+		for _, n := range next {
+			if used[n.key] || oldKeys[n.key] || n.parent != parent || n.level != o.level {
+				continue
+			}
+			if byItems == "" && o.items != "" && n.items == o.items {
+				byItems = n.key
+			}
+			if byIndex == "" && n.index == o.index {
+				byIndex = n.key
+			}
+		}
+		match := byItems
+		if match == "" {
+			match = byIndex
+		}
+		if match != "" {
+			mapping[o.key] = match
+			used[match] = true
+		}
+	}
+
+	renames := make(map[string]string)
+	for from, to := range mapping {
+		if from != to {
+			renames[from] = to
+		}
+	}
+	return renames
+}
+
+// renamePath returns the new key for a section path.
+func renamePath(path string, renames map[string]string) string {
+	if to, ok := renames[path]; ok {
+		return to
+	}
+	return path
+}
+
+// renameKeys returns keys with every renamed section path replaced, both as
+// section keys and as the section part of item fold keys (see foldKey).
+func renameKeys(keys map[string]bool, renames map[string]string) map[string]bool {
+	if len(renames) == 0 {
+		return keys
+	}
+	out := make(map[string]bool, len(keys))
+	for key, v := range keys {
+		out[renameFoldKey(renamePath(key, renames), renames)] = v
+	}
+	return out
+}
+
+// renameFoldKey returns an item fold key under a renamed section with the
+// section path replaced. The longest renamed path wins, since headings may
+// themselves contain "#".
+func renameFoldKey(key string, renames map[string]string) string {
+	from := ""
+	for old := range renames {
+		if len(old) > len(from) && strings.HasPrefix(key, old+"#") {
+			from = old
+		}
+	}
+	if from == "" {
+		return key
+	}
+	return renames[from] + key[len(from):]
+}
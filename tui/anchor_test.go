@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func reload(m *model, content string) {
+	m.setSnapshot(0, Parse(content), "")
+}
+
+func cursorTitle(m model) string {
+	n := m.nodes[m.cursor]
+	if n.isSection {
+		return "#" + n.key
+	}
+	return n.item.Title
+}
+
+func TestCursorFollowsItemWhenLinesInsertedAbove(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] A\n- [ ] B\n- [ ] C\n")
+	m.cursor = 2 // B
+
+	reload(&m, "## Tasks\n- [ ] New 1\n- [ ] New 2\n- [ ] A\n- [ ] B\n- [ ] C\n")
+	if got := cursorTitle(m); got != "B" {
+		t.Errorf("cursor on %q, want B", got)
+	}
+}
+
+func TestCursorFollowsDuplicateTitleByOccurrence(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] Same\n- [ ] Same\n")
+	m.cursor = 2 // second "Same"
+
+	reload(&m, "## Tasks\n- [ ] Other\n- [ ] Same\n- [ ] Same\n")
+	if m.cursor != 3 {
+		t.Errorf("cursor = %d, want 3", m.cursor)
+	}
+}
+
+func TestCursorFallsBackToNeighbour(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] A\n- [ ] B\n- [ ] C\n")
+	m.cursor = 2 // B
+
+	reload(&m, "## Tasks\n- [ ] A\n- [ ] C\n")
+	if got := cursorTitle(m); got != "C" {
+		t.Errorf("cursor on %q, want C", got)
+	}
+}
+
+func TestCollapseFollowsRenamedHeading(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] A\n  - [ ] A1\n### Active\n- [ ] B\n  - [ ] B1\n## Home\n- [ ] C\n")
+	m.collapsed["Work/Active"] = true
+	m.collapsed["Home"] = true
+	m.collapsed[foldKey("Work", "A")] = true
+	m.collapsed[foldKey("Work/Active", "B")] = true
+	m.refreshNodes()
+	m.cursor = 1 // A
+
+	reload(&m, "## Job\n- [ ] A\n  - [ ] A1\n### Active\n- [ ] B\n  - [ ] B1\n## House\n- [ ] C\n")
+
+	if !m.collapsed["Job/Active"] {
+		t.Error("expected Job/Active to stay collapsed after renaming its parent")
+	}
+	if !m.collapsed["House"] {
+		t.Error("expected House to stay collapsed after renaming")
+	}
+	if m.collapsed["Job"] {
+		t.Error("expected Job to stay expanded")
+	}
+	if !m.collapsed[foldKey("Job", "A")] || !m.collapsed[foldKey("Job/Active", "B")] {
+		t.Errorf("expected folded items to stay folded, got %v", m.collapsed)
+	}
+	for _, n := range m.nodes {
+		if !n.isSection && n.item.Title == "A1" {
+			t.Error("child of a folded item shown after renaming its section")
+		}
+	}
+	if got := cursorTitle(m); got != "A" {
+		t.Errorf("cursor on %q, want A", got)
+	}
+}
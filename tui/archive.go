@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// archiveFileName is the sibling file completed items are moved to when
+// archiving to a separate file.
+const archiveFileName = "archive.md"
+
+// defaultDoneHeading is the heading of the in-file section completed items are
+// moved to.
+const defaultDoneHeading = "Done"
+
+// archiveOptions controls what archiving moves and where it goes.
+type archiveOptions struct {
+	Heading       string // top-level heading of the in-file Done section
+	ToFile        bool   // move items to archive.md next to the file instead
+	WholeSections bool   // only archive sections whose items are all completed
+
+	Symbols map[byte]Status // checkbox symbols to parse with; nil means the defaults
+}
+
+// archiveFlags registers the archive options on flags.
+func archiveFlags(flags *flag.FlagSet) *archiveOptions {
+	opts := &archiveOptions{}
+	flags.StringVar(&opts.Heading, "done-heading", defaultDoneHeading, "heading of the section completed items are archived to")
+	flags.BoolVar(&opts.ToFile, "to-file", false, "archive completed items to "+archiveFileName+" next to the file")
+	flags.BoolVar(&opts.WholeSections, "whole-sections", false, "only archive sections whose items are all completed")
+	return opts
+}
+
+// archiveGroup holds the archived item blocks that came from one heading path.
+type archiveGroup struct {
+	path  string
+	lines []string
+}
+
+// archiveWrite is the change archiving makes to archive.md. It is applied
+// only once the archived file itself has been saved, so a failed save cannot
+// leave the items in both files.
+type archiveWrite struct {
+	path    string
+	existed bool // whether archive.md existed before
+	before  string
+	after   string
+}
+
+// apply writes the new content of archive.md.
+func (w *archiveWrite) apply() error {
+	return writeFileAtomic(w.path, []byte(w.after))
+}
+
+// archiveCompleted archives the completed items of the file at path, whose
+// current content is given, and returns the remaining content along with the
+// number of items archived. With opts.ToFile the items go to archive.md next
+// to path, described by the returned archiveWrite for the caller to apply
+// after saving the remaining content; otherwise they are appended to the
+// opts.Heading section of the returned content and the archiveWrite is nil.
+// Items are grouped under headings named after their original heading path.
+// Cancelled items are archived along with completed ones.
+func archiveCompleted(path, content string, opts archiveOptions) (string, *archiveWrite, int, error) {
+	remaining, groups, count := extractCompleted(content, opts)
+	if count == 0 {
+		return content, nil, 0, nil
+	}
+
+	if !opts.ToFile {
+		return appendArchive(remaining, opts.Heading, groups, opts.Symbols), nil, count, nil
+	}
+
+	w := &archiveWrite{path: filepath.Join(filepath.Dir(path), archiveFileName), existed: true}
+	existing, err := os.ReadFile(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.existed = false
+	} else if err != nil {
+		return "", nil, 0, err
+	}
+	w.before = string(existing)
+	base := w.before
+	if !w.existed {
+		base = "# Archive\n"
+	}
+	w.after = appendArchive(base, "", groups, opts.Symbols)
+	return remaining, w, count, nil
+}
+
+// archiveItems archives the completed items of file fi as configured for the
+// TUI. With an archive file, archive.md is written after the file itself has
+// been saved; if that fails the file is put back so no items are lost. Undo
+// restores both files.
+func (m *model) archiveItems(fi int) {
+	path, opts := m.files[fi].path, m.archive
+	opts.Symbols = m.symbols
+	var archive *archiveWrite
+	before, after, ok := m.writeEdit(path, func(content string) (string, error) {
+		remaining, w, _, err := archiveCompleted(path, content, opts)
+		archive = w
+		return remaining, err
+	})
+	if !ok {
+		return
+	}
+	if archive != nil {
+		if err := archive.apply(); err != nil {
+			m.restore(path, after, before)
+			m.err = err
+			return
+		}
+	}
+	m.recordEdit(editRecord{path: path, before: before, after: after, archive: archive})
+}
+
+// extractCompleted removes the items to archive from content and returns the
+// remaining content, the removed item blocks grouped by heading path and the
+// number of items removed. The Done section itself is never archived, nor is
+// a cancelled item with open children. With opts.WholeSections the headings of
+// archived sections that are left empty are removed too.
+func extractCompleted(content string, opts archiveOptions) (string, []archiveGroup, int) {
+	lines := strings.Split(content, "\n")
+
+	var groups []archiveGroup
+	var ranges [][2]int
+	emptied := make(map[string]bool)
+	var walk func(sections []TodoSection, prefix string, whole bool)
+	walk = func(sections []TodoSection, prefix string, whole bool) {
+		for _, s := range sections {
+			if prefix == "" && !opts.ToFile && s.Heading == opts.Heading {
+				continue
+			}
+			key := sectionKey(prefix, s.Heading)
+			take := whole || opts.WholeSections && s.AllCompleted
+			if take {
+				emptied[key] = true
+			}
+
+			for _, item := range s.Items {
+				if !item.closed() || !allItemsCompleted(item.Children) || opts.WholeSections && !take {
+					continue
+				}
+				end := itemBlockEnd(lines, item.Line, opts.Symbols)
+				ranges = append(ranges, [2]int{item.Line, end})
+
+				indent := leadingSpace(lines[item.Line-1])
+				var block []string
+				for _, l := range lines[item.Line-1 : end] {
+					block = append(block, strings.TrimPrefix(strings.TrimSuffix(l, "\r"), indent))
+				}
+				idx := slices.IndexFunc(groups, func(g archiveGroup) bool { return g.path == key })
+				if idx < 0 {
+					groups = append(groups, archiveGroup{path: key})
+					idx = len(groups) - 1
+				}
+				groups[idx].lines = append(groups[idx].lines, block...)
+			}
+			walk(s.Subsections, key, take)
+		}
+	}
+	walk(ParseWith(content, opts.Symbols), "", false)
+
+	// Remove from the bottom up so earlier line numbers stay valid
+	slices.SortFunc(ranges, func(a, b [2]int) int { return b[0] - a[0] })
+	for _, r := range ranges {
+		lines = append(lines[:r[0]-1], lines[r[1]:]...)
+	}
+	if len(emptied) > 0 {
+		lines = dropEmptySections(lines, emptied)
+	}
+	return strings.Join(lines, "\n"), groups, len(ranges)
+}
+
+// dropEmptySections removes the headings of the sections in keys that have
+// nothing left below them but blank lines and other removed headings.
+func dropEmptySections(lines []string, keys map[string]bool) []string {
+	var drop []int
+	var visit func(s TodoSection, prefix string) bool
+	visit = func(s TodoSection, prefix string) bool {
+		key := sectionKey(prefix, s.Heading)
+		empty := keys[key]
+		for _, sub := range s.Subsections {
+			if !visit(sub, key) {
+				empty = false
+			}
+		}
+		if !empty {
+			return false
+		}
+		end := sectionBlockEnd(lines, s)
+		if len(s.Subsections) > 0 {
+			end = s.Subsections[0].Line - 1
+		}
+		for _, l := range lines[s.Line:end] {
+			if strings.TrimSpace(l) != "" {
+				return false
+			}
+		}
+		drop = append(drop, s.Line)
+		return true
+	}
+	for _, s := range Parse(strings.Join(lines, "\n")) {
+		visit(s, "")
+	}
+
+	slices.Sort(drop)
+	for i := len(drop) - 1; i >= 0; i-- {
+		lines = slices.Delete(lines, drop[i]-1, drop[i])
+	}
+	return lines
+}
+
+// appendArchive adds the archived groups to content. With a heading, each
+// group becomes a subsection of that top-level section, which is created at
+// the end of the file if missing. With an empty heading (archive files) each
+// group becomes a top-level "##" section. Groups whose heading already exists
+// receive the new items after their existing ones.
+func appendArchive(content, heading string, groups []archiveGroup, symbols map[byte]Status) string {
+	for _, g := range groups {
+		lines := strings.Split(content, "\n")
+		sections := ParseWith(content, symbols)
+
+		level := 2
+		siblings := sections
+		insertAt := len(lines)
+		if heading != "" {
+			idx := slices.IndexFunc(sections, func(s TodoSection) bool { return s.Heading == heading })
+			if idx < 0 {
+				lines = appendAtEnd(lines, "", "## "+heading)
+				content = strings.Join(lines, "\n")
+				sections = ParseWith(content, symbols)
+				idx = slices.IndexFunc(sections, func(s TodoSection) bool { return s.Heading == heading })
+			}
+			done := sections[idx]
+			level = done.Level + 1
+			siblings = done.Subsections
+			insertAt = sectionBlockEnd(lines, done)
+		}
+
+		if idx := slices.IndexFunc(siblings, func(s TodoSection) bool { return s.Heading == g.path }); idx >= 0 {
+			after, _, gap := itemInsertPoint(lines, siblings[idx], symbols)
+			if gap {
+				lines = slices.Insert(lines, after, "")
+				after++
+			}
+			lines = slices.Insert(lines, after, g.lines...)
+		} else {
+			block := append([]string{strings.Repeat("#", level) + " " + g.path}, g.lines...)
+			if insertAt == len(lines) {
+				lines = appendAtEnd(lines, append([]string{""}, block...)...)
+			} else {
+				lines = slices.Insert(lines, insertAt, block...)
+			}
+		}
+		content = strings.Join(lines, "\n")
+	}
+	return content
+}
+
+// appendAtEnd appends newLines after the last non-blank line, keeping a single
+// trailing newline if the content had one. A leading "" in newLines acts as a
+// blank separator and is dropped when there is nothing to separate from.
+func appendAtEnd(lines []string, newLines ...string) []string {
+	trailingNewline := len(lines) > 0 && lines[len(lines)-1] == ""
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 && len(newLines) > 0 && newLines[0] == "" {
+		newLines = newLines[1:]
+	}
+	lines = append(lines, newLines...)
+	if trailingNewline {
+		lines = append(lines, "")
+	}
+	return lines
+}
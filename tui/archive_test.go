@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveToDoneSection(t *testing.T) {
+	content := "# Todo\n## Work\n### Active\n- [x] Shipped\n  shipped detail\n- [ ] Open\n## Personal\n- [x] Paid bill\n"
+
+	got, _, count, err := archiveCompleted("todo.md", content, archiveOptions{Heading: "Done"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	want := "# Todo\n## Work\n### Active\n- [ ] Open\n## Personal\n\n## Done\n### Work/Active\n- [x] Shipped\n  shipped detail\n### Personal\n- [x] Paid bill\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// Archiving again merges into the existing group headings
+	next := "# Todo\n## Work\n### Active\n- [x] Another\n" + got[len("# Todo\n## Work\n### Active\n"):]
+	got, _, count, err = archiveCompleted("todo.md", next, archiveOptions{Heading: "Done"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	want = "# Todo\n## Work\n### Active\n- [ ] Open\n## Personal\n\n## Done\n### Work/Active\n- [x] Shipped\n  shipped detail\n- [x] Another\n### Personal\n- [x] Paid bill\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestArchiveWholeSectionsOnly(t *testing.T) {
+	content := "## Mixed\n- [x] Done here\n- [ ] Open here\n## Finished\n- [x] A\n### Nested\n- [x] B\n"
+
+	got, _, count, err := archiveCompleted("todo.md", content, archiveOptions{Heading: "Done", WholeSections: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	want := "## Mixed\n- [x] Done here\n- [ ] Open here\n\n## Done\n### Finished\n- [x] A\n### Finished/Nested\n- [x] B\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestArchiveToFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.md")
+	content := "## Work\n- [x] Shipped\n- [ ] Open\n"
+
+	got, archive, count, err := archiveCompleted(path, content, archiveOptions{ToFile: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(archive.path); err == nil {
+		t.Fatal("archive.md should not be written before the file is saved")
+	}
+	if err := archive.apply(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	if want := "## Work\n- [ ] Open\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	archived, err := os.ReadFile(filepath.Join(dir, archiveFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Archive\n\n## Work\n- [x] Shipped\n"; string(archived) != want {
+		t.Errorf("archive = %q, want %q", archived, want)
+	}
+}
+
+func TestArchiveNothingCompleted(t *testing.T) {
+	content := "## Work\n- [ ] Open\n"
+	got, _, count, err := archiveCompleted("todo.md", content, archiveOptions{Heading: "Done"})
+	if err != nil || count != 0 || got != content {
+		t.Errorf("expected no-op, got %q count %d err %v", got, count, err)
+	}
+}
+
+func TestArchiveKeepsEmptyHeadingsThatHadContent(t *testing.T) {
+	content := "## Finished\nSome notes\n- [x] A\n### Nested\n- [x] B\n## Open\n- [ ] C\n"
+
+	got, _, _, err := archiveCompleted("todo.md", content, archiveOptions{Heading: "Done", WholeSections: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Finished\nSome notes\n## Open\n- [ ] C\n\n## Done\n### Finished\n- [x] A\n### Finished/Nested\n- [x] B\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestArchiveSkipsCancelledParentWithOpenChildren(t *testing.T) {
+	content := "## Work\n- [-] Dropped\n  - [ ] Still open\n- [-] Dropped too\n  - [x] Done child\n"
+
+	got, _, count, err := archiveCompleted("todo.md", content, archiveOptions{Heading: "Done"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	want := "## Work\n- [-] Dropped\n  - [ ] Still open\n\n## Done\n### Work\n- [-] Dropped too\n  - [x] Done child\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestArchiveToFileWaitsForSave(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [x] Shipped\n")
+	m.archive.ToFile = true
+	archivePath := filepath.Join(filepath.Dir(m.filePath), archiveFileName)
+
+	// An external change makes the save fail with a conflict
+	if err := os.WriteFile(m.filePath, []byte("## Work\n- [x] Shipped\n- [ ] New\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.archiveItems(0)
+	if !errors.Is(m.err, errConflict) {
+		t.Fatalf("err = %v, want errConflict", m.err)
+	}
+	if _, err := os.Stat(archivePath); err == nil {
+		t.Fatal("archive.md written although the file was not saved")
+	}
+
+	m.archiveItems(0)
+	if got := readFile(t, m.filePath); got != "## Work\n- [ ] New\n" {
+		t.Errorf("file = %q", got)
+	}
+	if got := readFile(t, archivePath); got != "# Archive\n\n## Work\n- [x] Shipped\n" {
+		t.Errorf("archive = %q", got)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Detail pane layout thresholds. Wide terminals get a right-hand pane; narrow
+// ones get a bottom pane if they are tall enough.
+const (
+	sidePaneMinWidth    = 100
+	bottomPaneMinHeight = 18
+)
+
+var (
+	// inlineBoldRegex matches **bold** spans in detail text.
+	inlineBoldRegex = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	// inlineCodeRegex matches `code` spans in detail text.
+	inlineCodeRegex = regexp.MustCompile("`([^`]+)`")
+	// inlineLinkRegex matches [text](url) links in detail text.
+	inlineLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+)
+
+// paneShown reports whether the detail pane is visible in the current mode
+// and terminal size.
+func (m model) paneShown() bool {
+	if !m.showDetails || m.mode == modeMove || m.mode == modeTags {
+		return false
+	}
+	return m.width >= sidePaneMinWidth || m.height >= bottomPaneMinHeight
+}
+
+// sidePaneWidth returns the width of the right-hand pane, including its border,
+// or 0 when the pane is not on the side.
+func (m model) sidePaneWidth() int {
+	if !m.paneShown() || m.width < sidePaneMinWidth {
+		return 0
+	}
+	return m.width * 2 / 5
+}
+
+// bottomPaneHeight returns the height of the bottom pane, including its
+// border, or 0 when the pane is not at the bottom.
+func (m model) bottomPaneHeight() int {
+	if !m.paneShown() || m.width >= sidePaneMinWidth {
+		return 0
+	}
+	return m.height / 3
+}
+
+// listWidth returns the width available to the tree.
+func (m model) listWidth() int {
+	return m.width - m.sidePaneWidth()
+}
+
+// renderPane renders the detail pane for the node under the cursor at the
+// given outer size.
+func (m model) renderPane(width, height int, side bool) string {
+	style := lipgloss.NewStyle().
+		BorderForeground(lipgloss.Color("#444444")).
+		Padding(0, 1)
+	if side {
+		style = style.Border(lipgloss.NormalBorder(), false, false, false, true)
+	} else {
+		style = style.Border(lipgloss.NormalBorder(), true, false, false, false)
+	}
+	innerWidth := max(width-style.GetHorizontalFrameSize(), 1)
+	innerHeight := max(height-style.GetVerticalFrameSize(), 1)
+
+	text := lipgloss.NewStyle().Width(innerWidth).Render(m.paneContent())
+	lines := strings.Split(text, "\n")
+	if len(lines) > innerHeight {
+		lines = lines[:innerHeight]
+	}
+	return style.Width(width - style.GetHorizontalBorderSize()).
+		Height(innerHeight).
+		Render(strings.Join(lines, "\n"))
+}
+
+// paneContent returns the styled text describing the node under the cursor.
+func (m model) paneContent() string {
+	if m.cursor >= len(m.nodes) {
+		return ""
+	}
+	n := m.nodes[m.cursor]
+	color := pastelColors[n.colorIdx%len(pastelColors)]
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(color)
+	metaStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	var b strings.Builder
+	if n.isSection {
+		done, total := sectionStats(n.section)
+		b.WriteString(titleStyle.Render(n.section.Heading) + "\n")
+		if n.isFile() {
+			b.WriteString(metaStyle.Render(m.files[m.fileIndex(n.key)].path) + "\n")
+		} else {
+			b.WriteString(metaStyle.Render(fmt.Sprintf("line %d · %s", n.section.Line, n.key)) + "\n")
+		}
+		b.WriteString(metaStyle.Render(fmt.Sprintf("%d/%d done", done, total)))
+		return b.String()
+	}
+
+	b.WriteString(titleStyle.Render(n.item.Title) + "\n")
+	b.WriteString(metaStyle.Render(fmt.Sprintf("line %d · %s · %s", n.item.Line, n.key, n.item.Status)) + "\n")
+	var dates []string
+	if !n.item.Due.IsZero() {
+		dates = append(dates, "due "+n.item.Due.Format(time.DateOnly))
+	}
+	if !n.item.Scheduled.IsZero() {
+		dates = append(dates, "scheduled "+n.item.Scheduled.Format(time.DateOnly))
+	}
+	if len(dates) > 0 {
+		b.WriteString(metaStyle.Render(strings.Join(dates, " · ")) + "\n")
+	}
+	if len(n.item.Tags) > 0 {
+		tagParts := make([]string, len(n.item.Tags))
+		for i, tag := range n.item.Tags {
+			tagParts[i] = "[" + tag + "]"
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(color).Render(strings.Join(tagParts, " ")) + "\n")
+	}
+	if len(n.item.Details) > 0 {
+		b.WriteString("\n")
+		for i, d := range n.item.Details {
+			// Code is shown as written, not as markdown
+			if !n.item.Code[i] {
+				d = renderDetailLine(d)
+			}
+			b.WriteString(d + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// renderDetailLine applies basic markdown styling to a detail line: list
+// bullets, headings, **bold**, `code` and [links](url).
+func renderDetailLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "#"):
+		return lipgloss.NewStyle().Bold(true).Underline(true).Render(strings.TrimSpace(strings.TrimLeft(line, "#")))
+	case strings.HasPrefix(line, "* "), strings.HasPrefix(line, "+ "):
+		line = "• " + line[2:]
+	case strings.HasPrefix(line, "> "):
+		return lipgloss.NewStyle().Faint(true).Italic(true).Render("│ " + line[2:])
+	}
+
+	bold := lipgloss.NewStyle().Bold(true)
+	code := lipgloss.NewStyle().Foreground(lipgloss.Color("#E6D14D"))
+	link := lipgloss.NewStyle().Foreground(lipgloss.Color("#66B3EB")).Underline(true)
+
+	line = inlineCodeRegex.ReplaceAllStringFunc(line, func(s string) string {
+		return code.Render(s[1 : len(s)-1])
+	})
+	line = inlineBoldRegex.ReplaceAllStringFunc(line, func(s string) string {
+		return bold.Render(s[2 : len(s)-2])
+	})
+	line = inlineLinkRegex.ReplaceAllStringFunc(line, func(s string) string {
+		match := inlineLinkRegex.FindStringSubmatch(s)
+		return link.Render(match[1])
+	})
+	return line
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRenderDetailLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"* star bullet", "• star bullet"},
+		{"+ plus bullet", "• plus bullet"},
+		{"- dash kept", "- dash kept"},
+		{"# Heading", "Heading"},
+		{"### Sub heading ", "Sub heading"},
+		{"> quoted", "│ quoted"},
+		{"see [the docs](https://example.com) first", "see the docs first"},
+		{"[a](x) and [b](y)", "a and b"},
+		{"**bold** and `code`", "bold and code"},
+		{"* [link](url) in a bullet", "• link in a bullet"},
+	}
+	for _, tt := range tests {
+		if got := ansi.Strip(renderDetailLine(tt.line)); got != tt.want {
+			t.Errorf("renderDetailLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestPaneShowsCodeDetailsAsWritten(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] Script **now**\n  see `notes`\n  ```sh\n  # a comment\n  ## not a heading\n  echo `x`\n  ```\n")
+	m.cursor = 1
+
+	got := ansi.Strip(m.paneContent())
+	for _, want := range []string{"see notes\n", "```sh\n# a comment\n## not a heading\necho `x`\n```"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("pane missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dateRegex matches a date token in checkbox text: a bare ISO date, a due: or
+// scheduled: key/value, or an Obsidian 📅 (due) or ⏳ (scheduled) date.
+var dateRegex = regexp.MustCompile(`(?:^|\s)(?:(due|scheduled):|(📅|⏳)\s?)?([0-9]{4}-[0-9]{2}-[0-9]{2})\b`)
+
+// Colours for due dates that need attention.
+var (
+	overdueColor  = lipgloss.Color("#FF6B6B")
+	dueTodayColor = lipgloss.Color("#FFD166")
+)
+
+// extractDates returns the due and scheduled dates written in checkbox text,
+// or zero times. An explicit due date wins over a bare ISO date, and the first
+// of each kind is used.
+func extractDates(text string) (due, scheduled time.Time) {
+	var bare time.Time
+	for _, match := range dateRegex.FindAllStringSubmatch(text, -1) {
+		date, err := time.ParseInLocation(time.DateOnly, match[3], time.Local)
+		if err != nil {
+			continue
+		}
+		switch {
+		case match[1] == "scheduled" || match[2] == "⏳":
+			if scheduled.IsZero() {
+				scheduled = date
+			}
+		case match[1] == "due" || match[2] == "📅":
+			if due.IsZero() {
+				due = date
+			}
+		case bare.IsZero():
+			bare = date
+		}
+	}
+	if due.IsZero() {
+		due = bare
+	}
+	return due, scheduled
+}
+
+// dateTokenStart returns the index of the first date token in text that does
+// not start it, or -1. Titles end there, as they do at tags. Tokens that are
+// not real dates, such as 2026-13-45, are part of the title.
+func dateTokenStart(text string) int {
+	for _, loc := range dateRegex.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] == 0 {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, text[loc[6]:loc[7]]); err == nil {
+			return loc[0]
+		}
+	}
+	return -1
+}
+
+// daysUntil returns the number of calendar days from now until date.
+func daysUntil(date, now time.Time) int {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	return int(math.Round(date.Sub(today).Hours() / 24))
+}
+
+// dueText describes a due date relative to now, such as "due tomorrow" or
+// "3d overdue".
+func dueText(due, now time.Time) string {
+	switch days := daysUntil(due, now); {
+	case days < 0:
+		return fmt.Sprintf("%dd overdue", -days)
+	case days == 0:
+		return "due today"
+	case days == 1:
+		return "due tomorrow"
+	case days < 7:
+		return fmt.Sprintf("due in %dd", days)
+	case due.Year() == now.Year():
+		return "due " + due.Format("Jan 2")
+	default:
+		return "due " + due.Format("Jan 2 2006")
+	}
+}
+
+// dueColor returns the colour of an item's due text: red when overdue, yellow
+// when due today and grey otherwise.
+func dueColor(due, now time.Time) lipgloss.Color {
+	switch days := daysUntil(due, now); {
+	case days < 0:
+		return overdueColor
+	case days == 0:
+		return dueTodayColor
+	default:
+		return lipgloss.Color("#888888")
+	}
+}
+
+// nextDue returns the earliest due date of an open item or its open children,
+// or a zero time.
+func nextDue(item *TodoItem) time.Time {
+	var next time.Time
+	visit := func(item *TodoItem) {
+		if !item.Due.IsZero() && !item.closed() && (next.IsZero() || item.Due.Before(next)) {
+			next = item.Due
+		}
+	}
+	visit(item)
+	eachItem(item.Children, visit)
+	return next
+}
+
+// compareDue orders items by their next due date, with undated items last.
+func compareDue(a, b *TodoItem) int {
+	da, db := nextDue(a), nextDue(b)
+	if da.IsZero() != db.IsZero() {
+		if da.IsZero() {
+			return 1
+		}
+		return -1
+	}
+	return da.Compare(db)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func mustDate(s string) time.Time {
+	d, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestParseDates(t *testing.T) {
+	markdown := "## Tasks\n" +
+		"- [ ] Pay rent 2026-11-01 [home]\n" +
+		"- [ ] Ship release due:2026-10-20 scheduled:2026-10-18\n" +
+		"- [ ] Call back 📅 2026-10-22 ⏳ 2026-10-21\n" +
+		"- [ ] Explicit wins 2026-01-01 due:2026-02-02\n" +
+		"- [ ] **Bold title** - Feb 7\n" +
+		"- [ ] Not a date 2026-13-45\n" +
+		"- [ ] Fix bug 2026-13-45 thing 2026-10-25\n" +
+		"- [ ] 2026-10-30 leading date"
+	items := Parse(markdown)[0].Items
+
+	tests := []struct {
+		title          string
+		due, scheduled string
+	}{
+		{"Pay rent", "2026-11-01", ""},
+		{"Ship release", "2026-10-20", "2026-10-18"},
+		{"Call back", "2026-10-22", "2026-10-21"},
+		{"Explicit wins", "2026-02-02", ""},
+		{"Bold title", "", ""},
+		{"Not a date 2026-13-45", "", ""},
+		{"Fix bug 2026-13-45 thing", "2026-10-25", ""},
+		{"2026-10-30 leading date", "2026-10-30", ""},
+	}
+	if len(items) != len(tests) {
+		t.Fatalf("expected %d items, got %d", len(tests), len(items))
+	}
+	for i, tt := range tests {
+		item := items[i]
+		if item.Title != tt.title {
+			t.Errorf("item %d title = %q, want %q", i, item.Title, tt.title)
+		}
+		if got := formatDate(item.Due); got != tt.due {
+			t.Errorf("%q due = %q, want %q", tt.title, got, tt.due)
+		}
+		if got := formatDate(item.Scheduled); got != tt.scheduled {
+			t.Errorf("%q scheduled = %q, want %q", tt.title, got, tt.scheduled)
+		}
+	}
+	if len(items[0].Tags) != 1 || items[0].Tags[0] != "home" {
+		t.Errorf("tags after a date = %v", items[0].Tags)
+	}
+}
+
+func formatDate(d time.Time) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(time.DateOnly)
+}
+
+func TestDueText(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		due  string
+		want string
+	}{
+		{"2026-10-14", "3d overdue"},
+		{"2026-10-17", "due today"},
+		{"2026-10-18", "due tomorrow"},
+		{"2026-10-21", "due in 4d"},
+		{"2026-12-25", "due Dec 25"},
+		{"2027-01-05", "due Jan 5 2027"},
+	}
+	for _, tt := range tests {
+		if got := dueText(mustDate(tt.due), now); got != tt.want {
+			t.Errorf("dueText(%s) = %q, want %q", tt.due, got, tt.want)
+		}
+	}
+	if dueColor(mustDate("2026-10-16"), now) != overdueColor || dueColor(mustDate("2026-10-17"), now) != dueTodayColor {
+		t.Error("overdue and due-today items should be coloured")
+	}
+}
+
+func TestEditItemKeepsDate(t *testing.T) {
+	content := "## Tasks\n- [ ] Ship due:2026-10-20 [a] - notes"
+
+	got, err := editItem(content, 2, "Ship it", []string{"b"}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n- [ ] Ship it [b] due:2026-10-20 - notes"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestByDueSortsAcrossSections(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] Later due:2026-12-01\n- [ ] Undated\n- [ ] Parent\n  - [ ] Soon due:2026-10-20\n- [x] Done due:2026-10-01\n## Home\n- [ ] Nothing due\n- [ ] Dentist due:2026-11-05")
+	m.width, m.height = 200, 40
+	m.collapsed["Home"] = true
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	m = updated.(model)
+
+	titles := func() []string {
+		var titles []string
+		for _, n := range m.nodes {
+			if n.isSection {
+				titles = append(titles, "## "+n.section.Heading)
+			} else {
+				titles = append(titles, n.item.Title)
+			}
+		}
+		return titles
+	}
+	// Undated and closed items keep their order after the dated ones
+	want := []string{"Parent", "Soon", "Dentist", "Later", "Undated", "Done", "Nothing due"}
+	if got := titles(); !slices.Equal(got, want) {
+		t.Fatalf("nodes = %q, want %q", got, want)
+	}
+	if view := m.View(); strings.Contains(view, "matches") || !strings.Contains(view, "[by due]") {
+		t.Errorf("sorting is not a filter, header:\n%s", view)
+	}
+
+	// Item folds still apply
+	m.collapsed[foldKey("Work", "Parent")] = true
+	m.refreshNodes()
+	if got := titles(); slices.Contains(got, "Soon") {
+		t.Errorf("folded child shown: %q", got)
+	}
+	if !m.uiState().ByDue {
+		t.Error("due view should be saved with the UI state")
+	}
+}
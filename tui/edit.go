@@ -0,0 +1,450 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// tagTokenRegex matches a [tag] token together with one leading space, so
+// removing it does not leave a double space behind.
+var tagTokenRegex = regexp.MustCompile(` ?\[[a-zA-Z][a-zA-Z0-9/]*\]`)
+
+// validTagRegex matches a tag name that extractTags will recognise.
+var validTagRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9/]*$`)
+
+// toggleCheckbox flips the checkbox on the given 1-based line of content,
+// turning "[x]"/"[X]" back into "[ ]" and any other status, such as "[ ]" or
+// "[/]", into "[x]". Everything else on the line, including indentation and
+// the list marker, is left untouched. Symbols map checkbox symbols to statuses
+// as in ParseWith. The edit helpers below take them too, so they recognise the
+// same checkboxes ParseWith did.
+func toggleCheckbox(content string, line int, symbols map[byte]Status) (string, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", fmt.Errorf("line %d out of range", line)
+	}
+
+	raw := lines[line-1]
+	indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+	rest := raw[indent:]
+
+	item, ok := parseCheckbox(rest, line, symbols)
+	if !ok {
+		return "", fmt.Errorf("line %d is not a checkbox", line)
+	}
+	mark := "x"
+	if item.Status == StatusDone {
+		mark = " "
+	}
+
+	at := indent + len(item.Marker) + 2 // past the marker and " ["
+	lines[line-1] = raw[:at] + mark + raw[at+1:]
+	return strings.Join(lines, "\n"), nil
+}
+
+// insertItem appends a new open checkbox with the given text after the last
+// direct item of section s (after the heading and any prose below it if it has
+// none). The new line
+// reuses the indentation and list marker of the section's last item, counting
+// on in ordered lists. It returns the updated content and the 1-based line
+// number of the inserted item.
+func insertItem(content string, s TodoSection, text string, symbols map[byte]Status) (string, int, error) {
+	lines := strings.Split(content, "\n")
+	if s.Line < 1 || s.Line > len(lines) {
+		return "", 0, fmt.Errorf("section %q not found in file", s.Heading)
+	}
+
+	after, indent, gap := itemInsertPoint(lines, s, symbols)
+	marker := "-"
+	if n := len(s.Items); n > 0 {
+		marker = nextMarker(s.Items[n-1].Marker)
+	}
+	cr := ""
+	if strings.HasSuffix(lines[after-1], "\r") {
+		cr = "\r"
+	}
+	block := []string{indent + marker + " [ ] " + text + cr}
+	if gap {
+		block = append([]string{cr}, block...)
+	}
+	lines = slices.Insert(lines, after, block...)
+	return strings.Join(lines, "\n"), after + len(block), nil
+}
+
+// itemInsertPoint returns the 1-based line after which a new item of section s
+// goes and the indentation to use for it. That is the end of its last direct
+// item or, when it has none, the end of the prose between its heading and its
+// first subsection, so the prose does not become the item's details. gap
+// reports that the item follows prose and needs a blank line before it.
+func itemInsertPoint(lines []string, s TodoSection, symbols map[byte]Status) (after int, indent string, gap bool) {
+	n := len(s.Items)
+	if n == 0 {
+		end := sectionIntroEnd(lines, s)
+		return end, "", end > s.Line
+	}
+	last := s.Items[n-1].Line
+	return itemBlockEnd(lines, last, symbols), leadingSpace(lines[last-1]), false
+}
+
+// sectionIntroEnd returns the last non-blank 1-based line between the heading
+// of section s and the next heading, or the heading line if there is nothing.
+func sectionIntroEnd(lines []string, s TodoSection) int {
+	end := s.Line
+	blocks := newBlockState()
+	blocks.next(lines[s.Line-1])
+	for next := s.Line; next < len(lines); next++ {
+		text := strings.TrimSpace(lines[next])
+		kind := blocks.next(lines[next])
+		if _, _, ok := parseHeading(text); ok && kind == blockText {
+			break
+		}
+		if text != "" && kind != blockComment {
+			end = next + 1
+		}
+	}
+	return end
+}
+
+// moveItem swaps the item on line with its previous (dir < 0) or next
+// (dir > 0) sibling in section s, carrying both items' detail lines and
+// children along. Nested items move among the children of their parent.
+// It returns the updated content and the item's new line number.
+func moveItem(content string, s TodoSection, line, dir int, symbols map[byte]Status) (string, int, error) {
+	siblings := siblingItems(s.Items, line)
+	idx := slices.IndexFunc(siblings, func(item TodoItem) bool { return item.Line == line })
+	if idx < 0 {
+		return "", 0, fmt.Errorf("item on line %d not found in %q", line, s.Heading)
+	}
+	other := idx + dir
+	if other < 0 || other >= len(siblings) {
+		return content, line, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	a, b := siblings[min(idx, other)].Line, siblings[max(idx, other)].Line
+	lines, bStart, aStart := swapBlocks(lines, a, itemBlockEnd(lines, a, symbols), b, itemBlockEnd(lines, b, symbols))
+	newLine := aStart
+	if dir < 0 {
+		newLine = bStart
+	}
+	return strings.Join(lines, "\n"), newLine, nil
+}
+
+// siblingItems returns the slice, among items and their descendants, that
+// holds the item on line, or nil.
+func siblingItems(items []TodoItem, line int) []TodoItem {
+	for _, item := range items {
+		if item.Line == line {
+			return items
+		}
+		if found := siblingItems(item.Children, line); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// moveSection swaps siblings[idx] with its previous (dir < 0) or next
+// (dir > 0) sibling. Each section moves together with its items and
+// subsections. It returns the updated content and the section's new heading line.
+func moveSection(content string, siblings []TodoSection, idx, dir int) (string, int, error) {
+	if idx < 0 || idx >= len(siblings) {
+		return "", 0, fmt.Errorf("section index %d out of range", idx)
+	}
+	other := idx + dir
+	if other < 0 || other >= len(siblings) {
+		return content, siblings[idx].Line, nil
+	}
+
+	lines := strings.Split(content, "\n")
+	a, b := siblings[min(idx, other)], siblings[max(idx, other)]
+	lines, bStart, aStart := swapBlocks(lines, a.Line, sectionBlockEnd(lines, a), b.Line, sectionBlockEnd(lines, b))
+	newLine := aStart
+	if dir < 0 {
+		newLine = bStart
+	}
+	return strings.Join(lines, "\n"), newLine, nil
+}
+
+// moveItemTo moves the item on line, with its detail lines and children, to
+// the end of the direct items of section target. The moved lines are re-indented to match
+// the target's items. It returns the updated content and the item's new line.
+func moveItemTo(content string, line int, target TodoSection, symbols map[byte]Status) (string, int, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", 0, fmt.Errorf("line %d out of range", line)
+	}
+	if _, ok := parseCheckbox(lines[line-1], line, symbols); !ok {
+		return "", 0, fmt.Errorf("line %d is not a checkbox", line)
+	}
+	if target.Line < 1 || target.Line > len(lines) {
+		return "", 0, fmt.Errorf("section %q not found in file", target.Heading)
+	}
+
+	end := itemBlockEnd(lines, line, symbols)
+	after, indent, gap := itemInsertPoint(lines, target, symbols)
+	if after >= line && after <= end {
+		// Already the last item of the target section
+		return content, line, nil
+	}
+
+	oldIndent := leadingSpace(lines[line-1])
+	block := make([]string, 0, end-line+1)
+	for _, l := range lines[line-1 : end] {
+		if strings.TrimSpace(l) != "" {
+			l = indent + strings.TrimPrefix(l, oldIndent)
+		}
+		block = append(block, l)
+	}
+
+	lines = append(lines[:line-1], lines[end:]...)
+	if after > end {
+		after -= len(block)
+	}
+	newLine := after + 1
+	if gap {
+		block = append([]string{""}, block...)
+		newLine++
+	}
+	lines = slices.Insert(lines, after, block...)
+	return strings.Join(lines, "\n"), newLine, nil
+}
+
+// swapBlocks exchanges the 1-based inclusive line ranges a and b, where a
+// comes before b, keeping whatever lies between them in place. It returns the
+// new lines and the new start lines of b and a.
+func swapBlocks(lines []string, aStart, aEnd, bStart, bEnd int) ([]string, int, int) {
+	out := make([]string, 0, len(lines))
+	out = append(out, lines[:aStart-1]...)
+	out = append(out, lines[bStart-1:bEnd]...)
+	out = append(out, lines[aEnd:bStart-1]...)
+	newA := len(out) + 1
+	out = append(out, lines[aStart-1:aEnd]...)
+	out = append(out, lines[bEnd:]...)
+	return out, aStart, newA
+}
+
+// sectionBlockEnd returns the last non-blank 1-based line of section s,
+// including its subsections, before the next heading of the same or a
+// higher level.
+func sectionBlockEnd(lines []string, s TodoSection) int {
+	end := s.Line
+	blocks := newBlockState()
+	blocks.next(lines[s.Line-1])
+	for next := s.Line; next < len(lines); next++ {
+		text := strings.TrimSpace(lines[next])
+		code := blocks.next(lines[next]) == blockCode
+		if level, _, ok := parseHeading(text); ok && level <= s.Level && !code {
+			break
+		}
+		if text != "" {
+			end = next + 1
+		}
+	}
+	return end
+}
+
+// leadingSpace returns the indentation at the start of line.
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// editItem rewrites the item on the given 1-based line with a new title, tags
+// and detail lines. A bold title stays bold, the tags are placed right after
+// the title and any trailing text such as " - Feb 7" is kept. Detail lines are
+// only rewritten when they differ from the ones currently in the file, and
+// never when they hold code or comments, which would not survive the rewrite.
+func editItem(content string, line int, title string, tags, details []string, symbols map[byte]Status) (string, error) {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return "", fmt.Errorf("line %d out of range", line)
+	}
+	item, ok := parseCheckbox(lines[line-1], line, symbols)
+	if !ok {
+		return "", fmt.Errorf("line %d is not a checkbox", line)
+	}
+	for _, tag := range tags {
+		if !validTagRegex.MatchString(tag) {
+			return "", fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	raw := strings.TrimSuffix(lines[line-1], "\r")
+	cr := ""
+	if len(raw) < len(lines[line-1]) {
+		cr = "\r"
+	}
+	indent := leadingSpace(raw)
+	prefix := raw[:len(indent)+item.checkboxWidth()]
+	lines[line-1] = prefix + rebuildItemText(raw[len(prefix):], title, tags) + cr
+
+	end := itemDetailsEnd(lines, line, symbols)
+	var current []string
+	verbatim := false // the details hold code or comments
+	blocks := newBlockState()
+	blocks.next(raw)
+	for _, l := range lines[line:end] {
+		kind := blocks.next(l)
+		text := strings.TrimSpace(l)
+		if kind != blockText || strings.Contains(text, "<!--") {
+			verbatim = true
+		}
+		switch {
+		case kind == blockCode && text != "":
+			current = append(current, text)
+		case kind == blockText:
+			if text = stripComments(text); text != "" {
+				current = append(current, detailPrefixRegex.ReplaceAllString(text, ""))
+			}
+		}
+	}
+	if !slices.EqualFunc(current, details, func(c, d string) bool { return c == strings.TrimSpace(d) }) {
+		if verbatim {
+			return "", errors.New("details with code or comments can only be changed in the editor")
+		}
+		detailIndent := indent + "  "
+		detailPrefix := ""
+		if end > line {
+			first := lines[line]
+			detailIndent = leadingSpace(first)
+			if strings.HasPrefix(strings.TrimSpace(first), "- ") {
+				detailPrefix = "- "
+			}
+		}
+		newDetails := make([]string, len(details))
+		for i, d := range details {
+			newDetails[i] = detailIndent + detailPrefix + d + cr
+		}
+		lines = append(lines[:line], append(newDetails, lines[end:]...)...)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// rebuildItemText replaces the title and tags in the text after a checkbox
+// marker while keeping everything else in place. Plain titles that would not
+// survive extractTitle (because they contain " [", " - " or a date) are made
+// bold.
+func rebuildItemText(text, title string, tags []string) string {
+	head, titleText, rest := "", title, ""
+	if boldStart := strings.Index(text, "**"); boldStart >= 0 && strings.Contains(text[boldStart+2:], "**") {
+		boldEnd := boldStart + 2 + strings.Index(text[boldStart+2:], "**")
+		head = strings.TrimLeft(tagTokenRegex.ReplaceAllString(text[:boldStart], ""), " ")
+		titleText = "**" + title + "**"
+		rest = text[boldEnd+2:]
+	} else {
+		end := len(text)
+		if idx := strings.Index(text, " ["); idx >= 0 {
+			end = idx
+		}
+		if idx := strings.Index(text[:end], " - "); idx >= 0 {
+			end = idx
+		}
+		if idx := dateTokenStart(text[:end]); idx >= 0 {
+			end = idx
+		}
+		if idx := strings.Index(text[:end], " <!--"); idx >= 0 {
+			end = idx
+		}
+		rest = text[end:]
+		if strings.Contains(title, " [") || strings.Contains(title, " - ") || dateTokenStart(title) >= 0 {
+			titleText = "**" + title + "**"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(head)
+	b.WriteString(titleText)
+	for _, tag := range tags {
+		b.WriteString(" [" + tag + "]")
+	}
+	b.WriteString(tagTokenRegex.ReplaceAllString(rest, ""))
+	return b.String()
+}
+
+// itemBlockEnd returns the last 1-based line belonging to the item that starts
+// at line: the checkbox itself, every line Parse would attach to it as a
+// detail and its nested children, up to the next heading or a checkbox that is
+// indented no deeper than the item. Trailing blank lines are not part of the
+// block.
+func itemBlockEnd(lines []string, line int, symbols map[byte]Status) int {
+	return blockEnd(lines, line, true, symbols)
+}
+
+// itemDetailsEnd returns the last 1-based line of the detail lines of the item
+// that starts at line, stopping at its first child.
+func itemDetailsEnd(lines []string, line int, symbols map[byte]Status) int {
+	return blockEnd(lines, line, false, symbols)
+}
+
+// blockEnd returns the last non-blank 1-based line of the item at line before
+// the next heading or checkbox. With children, checkboxes indented deeper than
+// the item belong to it and do not end the block. Headings and checkboxes in
+// code blocks do not end it either, and trailing comments are left out.
+func blockEnd(lines []string, line int, children bool, symbols map[byte]Status) int {
+	indent := indentWidth(lines[line-1])
+	end := line
+	blocks := newBlockState()
+	blocks.next(lines[line-1])
+	for next := line; next < len(lines); next++ {
+		text := strings.TrimSpace(lines[next])
+		kind := blocks.next(lines[next])
+		if kind == blockText {
+			if _, _, ok := parseHeading(text); ok {
+				break
+			}
+			if _, ok := parseCheckbox(text, 0, symbols); ok && (!children || indentWidth(lines[next]) <= indent) {
+				break
+			}
+		}
+		if text != "" && kind != blockComment {
+			end = next + 1
+		}
+	}
+	return end
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+// The original file's permissions are preserved.
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
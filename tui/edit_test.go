@@ -0,0 +1,574 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestToggleCheckbox(t *testing.T) {
+	content := "## Tasks\n- [ ] Open task\n  - [x] Indented done\n- [X] Upper done\nplain line"
+
+	got, err := toggleCheckbox(content, 2, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Tasks\n- [x] Open task\n  - [x] Indented done\n- [X] Upper done\nplain line"
+	if got != want {
+		t.Errorf("toggle line 2:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = toggleCheckbox(content, 3, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "## Tasks\n- [ ] Open task\n  - [ ] Indented done\n- [X] Upper done\nplain line"
+	if got != want {
+		t.Errorf("toggle line 3:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = toggleCheckbox(content, 4, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "## Tasks\n- [ ] Open task\n  - [x] Indented done\n- [ ] Upper done\nplain line"
+	if got != want {
+		t.Errorf("toggle line 4:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestToggleCheckboxStatuses(t *testing.T) {
+	content := "## Tasks\n- [/] Started\n- [-] Dropped"
+
+	got, err := toggleCheckbox(content, 2, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n- [x] Started\n- [-] Dropped"; got != want {
+		t.Errorf("toggle in-progress:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = toggleCheckbox(content, 3, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n- [/] Started\n- [x] Dropped"; got != want {
+		t.Errorf("toggle cancelled:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestToggleCheckboxPreservesCRLF(t *testing.T) {
+	content := "## Tasks\r\n- [ ] Task\r\n"
+	got, err := toggleCheckbox(content, 2, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "## Tasks\r\n- [x] Task\r\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestToggleCheckboxRejectsNonCheckbox(t *testing.T) {
+	content := "## Tasks\nplain line"
+	if _, err := toggleCheckbox(content, 2, nil); err == nil {
+		t.Error("expected error for non-checkbox line")
+	}
+	if _, err := toggleCheckbox(content, 5, nil); err == nil {
+		t.Error("expected error for out-of-range line")
+	}
+}
+
+func TestItemBlocksSpanCodeAndComments(t *testing.T) {
+	content := "## Tasks\n- [ ] Script\n  ```\n  - [ ] in code\n  ## in code\n  ```\n  <!-- note -->\n  detail\n  <!-- trailing -->\n- [ ] Next\n## Other"
+	lines := strings.Split(content, "\n")
+
+	if end := itemBlockEnd(lines, 2, nil); end != 8 {
+		t.Errorf("item block end = %d, want 8", end)
+	}
+	if end := sectionBlockEnd(lines, Parse(content)[0]); end != 10 {
+		t.Errorf("section block end = %d, want 10", end)
+	}
+
+	got, err := editItem(content, 2, "Run script", nil, Parse(content)[0].Items[0].Details, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := strings.Replace(content, "Script", "Run script", 1); got != want {
+		t.Errorf("unchanged details should keep comments:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestEditItemKeepsCodeAndComments(t *testing.T) {
+	content := "## Tasks\n- [ ] Item\n  <!-- keep me -->\n  detail\n  ```\n      code()\n  - x\n  ```\n- [ ] Next"
+	details := Parse(content)[0].Items[0].Details
+	if want := []string{"detail", "```", "    code()", "- x", "```"}; !slices.Equal(details, want) {
+		t.Fatalf("details = %q, want %q", details, want)
+	}
+
+	// The edit form trims its fields; that alone is not a change
+	var trimmed []string
+	for _, d := range details {
+		trimmed = append(trimmed, strings.TrimSpace(d))
+	}
+	got, err := editItem(content, 2, "Renamed", nil, trimmed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := strings.Replace(content, "Item", "Renamed", 1); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	if _, err := editItem(content, 2, "Item", nil, []string{"detail", "changed"}, nil); err == nil {
+		t.Error("expected an error rewriting details that hold code and comments")
+	}
+
+	// Inline comments stay on the lines they were written on
+	content = "## Tasks\n- [ ] Item [a] <!-- note -->\n  detail <!-- aside -->"
+	got, err = editItem(content, 2, "Renamed", []string{"b"}, []string{"detail"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n- [ ] Renamed [b] <!-- note -->\n  detail <!-- aside -->"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestRewritesKeepListMarker(t *testing.T) {
+	content := "## Tasks\n* [ ] Star\n2) [ ] **Bold** [a] - Feb 7\n  1. [x] Child"
+
+	got, err := toggleCheckbox(content, 2, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n* [x] Star\n2) [ ] **Bold** [a] - Feb 7\n  1. [x] Child"; got != want {
+		t.Errorf("toggle:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = toggleCheckbox(content, 4, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n* [ ] Star\n2) [ ] **Bold** [a] - Feb 7\n  1. [ ] Child"; got != want {
+		t.Errorf("toggle child:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, err = editItem(content, 3, "Renamed", []string{"b"}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n* [ ] Star\n2) [ ] **Renamed** [b] - Feb 7\n  1. [x] Child"; got != want {
+		t.Errorf("edit:\ngot  %q\nwant %q", got, want)
+	}
+
+	got, line, err := insertItem(content, Parse(content)[0], "Third", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Tasks\n* [ ] Star\n2) [ ] **Bold** [a] - Feb 7\n  1. [x] Child\n3) [ ] Third"; got != want || line != 5 {
+		t.Errorf("insert at line %d:\ngot  %q\nwant %q", line, got, want)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.md")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("content = %q, want %q", data, "new")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("perm = %v, want 0600", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the target file in dir, got %d entries", len(entries))
+	}
+}
+
+func TestApplyEditRefusesExternalChange(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(path, []byte("## Tasks\n- [ ] Task\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sections, hash, err := ReadAndParse(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel(path, "todo.md", sections, hash)
+
+	external := "## Tasks\n- [ ] Task\n- [ ] Added elsewhere\n"
+	if err := os.WriteFile(path, []byte(external), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m.cursor = 1
+	m.toggleItem()
+
+	if !errors.Is(m.err, errConflict) {
+		t.Fatalf("err = %v, want errConflict", m.err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != external {
+		t.Errorf("file was overwritten: %q", data)
+	}
+	if len(m.sections[0].Items) != 2 {
+		t.Errorf("expected model reloaded with 2 items, got %d", len(m.sections[0].Items))
+	}
+
+	// A retry against the reloaded snapshot goes through
+	m.toggleItem()
+	if m.err != nil {
+		t.Fatalf("unexpected error on retry: %v", m.err)
+	}
+	if !m.sections[0].Items[0].Completed {
+		t.Error("expected first item to be completed after retry")
+	}
+}
+
+func TestEditFormRefusesExternalInsert(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] Alpha\n")
+	m.cursor = 1
+	m = pressKey(m, "i")
+
+	// Another tool inserts an item above the one being edited
+	changed := "## Tasks\n- [ ] Inserted\n- [ ] Alpha\n"
+	if err := os.WriteFile(m.filePath, []byte(changed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+
+	m.form[formTitle] = newLineInput("AlphaX")
+	m.saveEdit()
+	if !errors.Is(m.err, errConflict) {
+		t.Errorf("expected a conflict, got %v", m.err)
+	}
+	if got := readFile(t, m.filePath); got != changed {
+		t.Errorf("file was rewritten: %q", got)
+	}
+}
+
+func TestMovePickerRefusesExternalInsert(t *testing.T) {
+	m := newTestModel(t, "## A\n- [ ] One\n## B\n")
+	m.cursor = 1
+	m = pressKey(m, "m")
+
+	changed := "## A\n- [ ] Zero\n- [ ] One\n## B\n"
+	if err := os.WriteFile(m.filePath, []byte(changed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+
+	m.targetCursor = 1
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if !errors.Is(m.err, errConflict) {
+		t.Errorf("expected a conflict, got %v", m.err)
+	}
+	if got := readFile(t, m.filePath); got != changed {
+		t.Errorf("file was rewritten: %q", got)
+	}
+}
+
+func TestInsertItemAfterLastItemAndDetails(t *testing.T) {
+	content := "## Work\n  - [ ] First\n  - [ ] Second\n    detail line\n\n### Sub\n- [ ] Nested"
+	sections := Parse(content)
+
+	got, line, err := insertItem(content, sections[0], "New task [api]", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Work\n  - [ ] First\n  - [ ] Second\n    detail line\n  - [ ] New task [api]\n\n### Sub\n- [ ] Nested"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 5 {
+		t.Errorf("line = %d, want 5", line)
+	}
+
+	item := Parse(got)[0].Items[2]
+	if item.Title != "New task" || len(item.Tags) != 1 || item.Tags[0] != "api" {
+		t.Errorf("unexpected parsed item: %+v", item)
+	}
+}
+
+func TestInsertItemIntoEmptySection(t *testing.T) {
+	content := "## Empty\n## Other\n- [ ] Task"
+	sections := Parse(content)
+
+	got, line, err := insertItem(content, sections[0], "First", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Empty\n- [ ] First\n## Other\n- [ ] Task"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 2 {
+		t.Errorf("line = %d, want 2", line)
+	}
+}
+
+func TestInsertItemAfterSectionProse(t *testing.T) {
+	content := "## Notes\n\nBackground for this area.\n### Sub\n- [ ] Task\n## Other\n- [ ] Elsewhere"
+	sections := Parse(content)
+
+	got, line, err := insertItem(content, sections[0], "First", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Notes\n\nBackground for this area.\n\n- [ ] First\n### Sub\n- [ ] Task\n## Other\n- [ ] Elsewhere"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 5 {
+		t.Errorf("line = %d, want 5", line)
+	}
+	if item := Parse(got)[0].Items[0]; item.Title != "First" || len(item.Details) != 0 {
+		t.Errorf("the prose should not become details: %+v", item)
+	}
+
+	got, line, err = moveItemTo(content, 7, sections[0], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "## Notes\n\nBackground for this area.\n\n- [ ] Elsewhere\n### Sub\n- [ ] Task\n## Other"
+	if got != want {
+		t.Errorf("move:\ngot  %q\nwant %q", got, want)
+	}
+	if line != 5 {
+		t.Errorf("moved line = %d, want 5", line)
+	}
+}
+
+func TestEditItemKeepsBoldAndDate(t *testing.T) {
+	content := "## Work\n- [ ] **Multi-exchange secmaster** [ssmd] - Feb 7\n  - first detail\n- [ ] Next"
+
+	got, err := editItem(content, 2, "Secmaster v2", []string{"ssmd", "api/v2"}, []string{"first detail"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Work\n- [ ] **Secmaster v2** [ssmd] [api/v2] - Feb 7\n  - first detail\n- [ ] Next"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestEditItemPlainTitle(t *testing.T) {
+	content := "## Work\n- [x] Old title [a] - Feb 7"
+
+	got, err := editItem(content, 2, "New title", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Work\n- [x] New title - Feb 7"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// A title that extractTitle would cut short is written in bold
+	got, err = editItem(content, 2, "Fix a - b", []string{"a"}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	item := Parse(got)[0].Items[0]
+	if item.Title != "Fix a - b" {
+		t.Errorf("title = %q, want %q", item.Title, "Fix a - b")
+	}
+}
+
+func TestEditItemRewritesDetails(t *testing.T) {
+	content := "## Work\n  - [ ] Task\n    old one\n\n    old two\n  - [ ] Next\n    keep"
+
+	got, err := editItem(content, 2, "Task", nil, []string{"new one", "new two", "new three"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Work\n  - [ ] Task\n    new one\n    new two\n    new three\n  - [ ] Next\n    keep"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	items := Parse(got)[0].Items
+	if !slices.Equal(items[0].Details, []string{"new one", "new two", "new three"}) {
+		t.Errorf("details = %v", items[0].Details)
+	}
+	if !slices.Equal(items[1].Details, []string{"keep"}) {
+		t.Errorf("next item details = %v", items[1].Details)
+	}
+
+	got, err = editItem(content, 2, "Task", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "## Work\n  - [ ] Task\n  - [ ] Next\n    keep"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestEditItemRejectsInvalidTag(t *testing.T) {
+	if _, err := editItem("- [ ] Task", 1, "Task", []string{"1bad"}, nil, nil); err == nil {
+		t.Error("expected error for invalid tag")
+	}
+}
+
+func TestMoveItem(t *testing.T) {
+	content := "## Work\n- [ ] First\n  first detail\n\n- [ ] Second\n- [ ] Third\n  third detail\n### Sub"
+	s := Parse(content)[0]
+
+	got, line, err := moveItem(content, s, 5, -1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## Work\n- [ ] Second\n\n- [ ] First\n  first detail\n- [ ] Third\n  third detail\n### Sub"
+	if got != want {
+		t.Errorf("move up:\ngot  %q\nwant %q", got, want)
+	}
+	if line != 2 {
+		t.Errorf("line = %d, want 2", line)
+	}
+
+	got, line, err = moveItem(content, s, 2, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("move down:\ngot  %q\nwant %q", got, want)
+	}
+	if line != 4 {
+		t.Errorf("line = %d, want 4", line)
+	}
+
+	// Moving past the end is a no-op
+	got, line, err = moveItem(content, s, 6, 1, nil)
+	if err != nil || got != content || line != 6 {
+		t.Errorf("expected no-op, got line %d err %v", line, err)
+	}
+}
+
+func TestMoveSectionCarriesSubsections(t *testing.T) {
+	content := "# Title\n## A\n- [ ] a\n### A1\n- [ ] a1\n\n## B\n- [ ] b\n"
+	sections := Parse(content)
+
+	got, line, err := moveSection(content, sections, 1, -1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "# Title\n## B\n- [ ] b\n\n## A\n- [ ] a\n### A1\n- [ ] a1\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 2 {
+		t.Errorf("line = %d, want 2", line)
+	}
+
+	moved := Parse(got)
+	if moved[1].Heading != "A" || len(moved[1].Subsections) != 1 {
+		t.Errorf("expected A with its subsection second, got %+v", moved[1])
+	}
+}
+
+func TestMoveItemToSection(t *testing.T) {
+	content := "## A\n- [ ] Move me\n  detail\n- [ ] Stay\n## B\n  - [ ] Existing\n## C"
+	sections := Parse(content)
+
+	got, line, err := moveItemTo(content, 2, sections[1], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "## A\n- [ ] Stay\n## B\n  - [ ] Existing\n  - [ ] Move me\n    detail\n## C"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 5 {
+		t.Errorf("line = %d, want 5", line)
+	}
+
+	// Moving backwards into an empty section
+	got, line, err = moveItemTo(content, 6, sections[2], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "## A\n- [ ] Move me\n  detail\n- [ ] Stay\n## B\n## C\n- [ ] Existing"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 7 {
+		t.Errorf("line = %d, want 7", line)
+	}
+
+	got, line, err = moveItemTo(content, 6, sections[0], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "## A\n- [ ] Move me\n  detail\n- [ ] Stay\n- [ ] Existing\n## B\n## C"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if line != 5 {
+		t.Errorf("line = %d, want 5", line)
+	}
+}
+
+func TestNestedItemBlocks(t *testing.T) {
+	content := "## Work\n- [ ] Parent\n  detail\n  - [ ] A\n    a detail\n  - [ ] B\n- [ ] Next\n"
+	lines := strings.Split(content, "\n")
+	if end := itemBlockEnd(lines, 2, nil); end != 6 {
+		t.Errorf("parent block ends at %d, want 6", end)
+	}
+	if end := itemBlockEnd(lines, 4, nil); end != 5 {
+		t.Errorf("child block ends at %d, want 5", end)
+	}
+
+	// Editing a parent's details leaves its children alone
+	got, err := editItem(content, 2, "Parent", nil, []string{"new detail"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Work\n- [ ] Parent\n  new detail\n  - [ ] A\n    a detail\n  - [ ] B\n- [ ] Next\n"; got != want {
+		t.Errorf("edit:\ngot  %q\nwant %q", got, want)
+	}
+
+	// Children move among their siblings, parents carry their children
+	s := Parse(content)[0]
+	got, line, err := moveItem(content, s, 6, -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Work\n- [ ] Parent\n  detail\n  - [ ] B\n  - [ ] A\n    a detail\n- [ ] Next\n"; got != want || line != 4 {
+		t.Errorf("move child:\ngot  %q (line %d)\nwant %q", got, line, want)
+	}
+	got, line, err = moveItem(content, s, 7, -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Work\n- [ ] Next\n- [ ] Parent\n  detail\n  - [ ] A\n    a detail\n  - [ ] B\n"; got != want || line != 2 {
+		t.Errorf("move parent:\ngot  %q (line %d)\nwant %q", got, line, want)
+	}
+}
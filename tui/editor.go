@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// EditorFinishedMsg is sent when the external editor exits.
+type EditorFinishedMsg struct {
+	Err error
+}
+
+// editorCommand returns the user's editor command line, preferring $VISUAL
+// over $EDITOR and falling back to vi.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// OpenEditor returns a tea.Cmd that suspends the TUI, opens path in the
+// user's editor at the given line and sends EditorFinishedMsg on exit.
+func OpenEditor(path string, line int) tea.Cmd {
+	return tea.ExecProcess(editorExec(path, line), func(err error) tea.Msg {
+		return EditorFinishedMsg{Err: err}
+	})
+}
+
+// editorExec builds the command that opens path in the editor at line.
+func editorExec(path string, line int) *exec.Cmd {
+	editor := editorCommand()
+	args := append(editor[1:], fmt.Sprintf("+%d", line), path)
+	return exec.Command(editor[0], args...)
+}
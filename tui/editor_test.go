@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEditorExec(t *testing.T) {
+	tests := []struct {
+		name           string
+		visual, editor string
+		want           []string
+	}{
+		{"visual preferred", "nano", "vim", []string{"nano", "+12", "/tmp/todo.md"}},
+		{"editor fallback", "", "vim", []string{"vim", "+12", "/tmp/todo.md"}},
+		{"blank visual skipped", "  ", "vim", []string{"vim", "+12", "/tmp/todo.md"}},
+		{"arguments kept", "code --wait", "", []string{"code", "--wait", "+12", "/tmp/todo.md"}},
+		{"vi default", "", "", []string{"vi", "+12", "/tmp/todo.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VISUAL", tt.visual)
+			t.Setenv("EDITOR", tt.editor)
+			if got := editorExec("/tmp/todo.md", 12).Args; !slices.Equal(got, tt.want) {
+				t.Errorf("args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// todoFile is one markdown file shown in the tree, with the snapshot it was
+// last parsed from.
+type todoFile struct {
+	path     string // absolute path
+	name     string // display name, also the file's top-level key
+	sections []TodoSection
+	hash     string // content hash of the snapshot sections were parsed from
+	missing  bool   // deleted on disk; the last snapshot is kept until it returns
+}
+
+// sources describes what the TUI was opened on and decides which files belong
+// in the tree, both at startup and when new files appear.
+type sources struct {
+	files []string // files named explicitly
+	dirs  []string // directories searched recursively for .md files
+	globs []string // patterns matched against files as they appear
+}
+
+// resolveSources sorts command line paths into files, directories and glob
+// patterns. Paths that do not exist are treated as glob patterns when they
+// contain glob metacharacters and are an error otherwise.
+func resolveSources(args []string) (sources, error) {
+	var src sources
+	for _, arg := range args {
+		absPath, err := filepath.Abs(arg)
+		if err != nil {
+			return src, fmt.Errorf("resolving path: %w", err)
+		}
+		info, err := os.Stat(absPath)
+		switch {
+		case err == nil && info.IsDir():
+			src.dirs = append(src.dirs, absPath)
+		case err == nil:
+			src.files = append(src.files, absPath)
+		case strings.ContainsAny(arg, "*?["):
+			if _, err := filepath.Match(absPath, ""); err != nil {
+				return src, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			src.globs = append(src.globs, absPath)
+		default:
+			return src, err
+		}
+	}
+	return src, nil
+}
+
+// expand returns every file currently matched by the sources, sorted and
+// without duplicates.
+func (src sources) expand() ([]string, error) {
+	paths := slices.Clone(src.files)
+	for _, dir := range src.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isTodoFile(path) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range src.globs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+// matches reports whether a file at path belongs in the tree.
+func (src sources) matches(path string) bool {
+	if slices.Contains(src.files, path) {
+		return true
+	}
+	for _, dir := range src.dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) && isTodoFile(path) && !hiddenPath(rel) {
+			return true
+		}
+	}
+	for _, pattern := range src.globs {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// explicit reports whether path was named on the command line, as opposed to
+// being found in a directory or by a pattern.
+func (src sources) explicit(path string) bool {
+	return slices.Contains(src.files, path)
+}
+
+// watchDirs returns the directories to watch: every directory below the
+// searched directories, the parent directories of files and the directories
+// matches of patterns can appear in.
+func (src sources) watchDirs() ([]string, error) {
+	var dirs []string
+	for _, dir := range src.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range src.files {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	for _, pattern := range src.globs {
+		dirs = append(dirs, globDirs(pattern)...)
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs), nil
+}
+
+// globDirs returns the existing directories that files matching pattern, or
+// directories leading to them, can appear in: the matches of the pattern's
+// directory and of each of its parents up to the first one without glob
+// metacharacters. For ~/projects/*/TODO.md that is ~/projects and every
+// directory in it.
+func globDirs(pattern string) []string {
+	var dirs []string
+	for dir := filepath.Dir(pattern); ; dir = filepath.Dir(dir) {
+		matches, _ := filepath.Glob(dir)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				dirs = append(dirs, match)
+			}
+		}
+		if !strings.ContainsAny(dir, "*?[") {
+			return dirs
+		}
+	}
+}
+
+// globDir reports whether a new directory at path is one that globDirs would
+// return for a pattern with a wildcard in a directory name.
+func (src sources) globDir(path string) bool {
+	for _, pattern := range src.globs {
+		for dir := filepath.Dir(pattern); strings.ContainsAny(dir, "*?["); dir = filepath.Dir(dir) {
+			if ok, _ := filepath.Match(dir, path); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recursive reports whether new directories created at path should be
+// searched and watched.
+func (src sources) recursive(path string) bool {
+	for _, dir := range src.dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) && !hiddenPath(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTodoFile reports whether a file found in a directory should be shown:
+// visible markdown files other than the archive file.
+func isTodoFile(path string) bool {
+	base := filepath.Base(path)
+	return filepath.Ext(base) == ".md" && !strings.HasPrefix(base, ".") && base != archiveFileName
+}
+
+// hiddenPath reports whether any element of a relative path is hidden.
+func hiddenPath(rel string) bool {
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// displayName returns the name a file is shown under: its path relative to the
+// searched directory it was found in, or its base name.
+func (src sources) displayName(path string) string {
+	for _, dir := range src.dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(path)
+}
+
+// loadFiles reads and parses the files at paths for the initial view with the
+// given checkbox symbols, naming each after where it was found and ordering
+// them by name.
+func loadFiles(src sources, paths []string, symbols map[byte]Status) ([]todoFile, error) {
+	var files []todoFile
+	for _, path := range paths {
+		sections, hash, err := ReadAndParse(path, symbols)
+		if err != nil {
+			return nil, err
+		}
+		files = insertFile(files, todoFile{path: path, name: src.displayName(path), sections: sections, hash: hash})
+	}
+	return files, nil
+}
+
+// insertFile adds f to files in name order. A name that is already taken is
+// replaced by the full path, since names key the file's sections.
+func insertFile(files []todoFile, f todoFile) []todoFile {
+	if slices.ContainsFunc(files, func(o todoFile) bool { return o.name == f.name }) {
+		f.name = f.path
+	}
+	i, _ := slices.BinarySearchFunc(files, f.name, func(o todoFile, name string) int {
+		return strings.Compare(o.name, name)
+	})
+	return slices.Insert(files, i, f)
+}
+
+// missingFiles returns the names of the files that are waiting to reappear.
+func (m model) missingFiles() []string {
+	var names []string
+	for _, f := range m.files {
+		if f.missing {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// fileByPath returns the index of the file at path, or -1.
+func (m model) fileByPath(path string) int {
+	return slices.IndexFunc(m.files, func(f todoFile) bool { return f.path == path })
+}
+
+// applyFileChanges applies the watcher's messages for changed files.
+func (m *model) applyFileChanges(msgs FilesChangedMsg) {
+	for _, msg := range msgs {
+		switch msg := msg.(type) {
+		case FileUpdatedMsg:
+			m.updateFile(msg.Path, msg.Sections, msg.Hash)
+		case FileErrorMsg:
+			m.fileError(msg.Path, msg.Err)
+		}
+	}
+}
+
+// updateFile shows a new snapshot of the file at path. A file that is not in
+// the tree yet is added when it belongs to the sources. A snapshot with the
+// content already shown, such as our own write coming back from the watcher,
+// is ignored.
+func (m *model) updateFile(path string, sections []TodoSection, hash string) {
+	fi := m.fileByPath(path)
+	if fi >= 0 && !m.files[fi].missing && m.files[fi].hash == hash {
+		return
+	}
+	if fi < 0 {
+		if !m.multi || !m.src.matches(path) {
+			return
+		}
+		m.files = insertFile(m.files, todoFile{path: path, name: m.src.displayName(path)})
+		m.compose()
+		fi = m.fileByPath(path)
+	}
+	m.noteChanges(fi, sections)
+	m.setSnapshot(fi, sections, hash)
+}
+
+// fileError handles a file that could not be read. A deleted file that was
+// found in a directory or by a pattern leaves the tree. Any other deleted file
+// keeps its last snapshot and is marked missing until it reappears, which the
+// watcher reports like any other change. Other errors are reported.
+func (m *model) fileError(path string, err error) {
+	if !errors.Is(err, fs.ErrNotExist) {
+		m.err = err
+		return
+	}
+	fi := m.fileByPath(path)
+	if fi < 0 {
+		return
+	}
+	if !m.multi || m.src.explicit(path) {
+		m.files[fi].missing = true
+		m.ensureVisible()
+		return
+	}
+
+	anchors := m.cursorAnchors()
+	prefix := m.files[fi].name + ":"
+	m.files = slices.Delete(m.files, fi, fi+1)
+	m.compose()
+	for key := range m.changed {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.changed, key)
+		}
+	}
+	m.refreshNodes()
+	m.restoreCursor(anchors, nil)
+}
+
+// reload re-reads every file from disk. With several files the sources are
+// searched again, so files that appeared or disappeared in the meantime are
+// picked up too.
+func (m *model) reload() {
+	paths := make([]string, len(m.files))
+	for i, f := range m.files {
+		paths[i] = f.path
+	}
+	if m.multi {
+		found, err := m.src.expand()
+		if err != nil {
+			m.err = err
+		}
+		paths = append(paths, found...)
+		slices.Sort(paths)
+		paths = slices.Compact(paths)
+	}
+	m.applyFileChanges(readChanged(paths, m.symbols))
+}
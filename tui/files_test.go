@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newDirModel(t *testing.T, files map[string]string) (model, string) {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	src := sources{dirs: []string{dir}}
+	paths, err := src.expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadFiles(src, paths, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return initialMultiModel(dir, filepath.Base(dir), src, loaded), dir
+}
+
+func TestSourcesExpandDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md":          "",
+		"sub/b.md":      "",
+		"notes.txt":     "",
+		archiveFileName: "",
+		".hidden/c.md":  "",
+	})
+	src := sources{dirs: []string{dir}}
+	paths, err := src.expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "sub", "b.md")}
+	if !slices.Equal(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+	if !src.matches(filepath.Join(dir, "new", "d.md")) {
+		t.Error("expected new .md file below the directory to match")
+	}
+	if src.matches(filepath.Join(dir, ".hidden", "d.md")) || src.matches(filepath.Join(dir, "d.txt")) {
+		t.Error("expected hidden and non-markdown files not to match")
+	}
+}
+
+func TestResolveSourcesGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.md": "", "b.md": "", "c.txt": ""})
+	src, err := resolveSources([]string{filepath.Join(dir, "*.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := src.expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("expected 2 matches, got %v", paths)
+	}
+	if !src.matches(filepath.Join(dir, "new.md")) {
+		t.Error("expected a new file matching the pattern to match")
+	}
+
+	if _, err := resolveSources([]string{filepath.Join(dir, "missing.md")}); err == nil {
+		t.Error("expected an error for a missing path without a pattern")
+	}
+}
+
+func TestMultiFileTree(t *testing.T) {
+	m, _ := newDirModel(t, map[string]string{
+		"a.md":     "## Work\n- [ ] A1\n- [x] A2\n",
+		"sub/b.md": "## Home\n- [ ] B1\n",
+	})
+
+	var rows []string
+	for i := range m.nodes {
+		m.cursor = i
+		rows = append(rows, cursorTitle(m))
+	}
+	want := []string{"#a.md", "#a.md/Work", "A1", "A2", "#sub/b.md", "#sub/b.md/Home", "B1"}
+	if !slices.Equal(rows, want) {
+		t.Errorf("got rows %v, want %v", rows, want)
+	}
+	if done, total := m.countStats(); done != 1 || total != 3 {
+		t.Errorf("expected combined 1/3, got %d/%d", done, total)
+	}
+}
+
+func TestMultiFileEditsTargetOwnFile(t *testing.T) {
+	m, dir := newDirModel(t, map[string]string{
+		"a.md": "## Work\n- [ ] A1\n",
+		"b.md": "## Home\n- [ ] B1\n",
+	})
+
+	m.cursor = 5 // B1
+	m.toggleItem()
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if got := readFile(t, filepath.Join(dir, "b.md")); got != "## Home\n- [x] B1\n" {
+		t.Errorf("unexpected b.md: %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.md")); got != "## Work\n- [ ] A1\n" {
+		t.Errorf("a.md should be untouched, got %q", got)
+	}
+	if got := cursorTitle(m); got != "B1" {
+		t.Errorf("cursor on %q, want B1", got)
+	}
+
+	m.addItem("b.md/Home", "B2")
+	if got := readFile(t, filepath.Join(dir, "b.md")); got != "## Home\n- [x] B1\n- [ ] B2\n" {
+		t.Errorf("unexpected b.md after add: %q", got)
+	}
+	if got := cursorTitle(m); got != "B2" {
+		t.Errorf("cursor on %q, want B2", got)
+	}
+
+	m.undo()
+	m.undo()
+	if got := readFile(t, filepath.Join(dir, "b.md")); got != "## Home\n- [ ] B1\n" {
+		t.Errorf("unexpected b.md after undo: %q", got)
+	}
+}
+
+func TestMultiFileAddAndRemoveFiles(t *testing.T) {
+	m, dir := newDirModel(t, map[string]string{"a.md": "## Work\n- [ ] A1\n"})
+
+	path := filepath.Join(dir, "new", "c.md")
+	writeFiles(t, dir, map[string]string{"new/c.md": "## Later\n- [ ] C1\n"})
+	m.applyFileChanges(readChanged([]string{path, filepath.Join(dir, "notes.txt")}, nil))
+	if len(m.files) != 2 || m.files[1].name != "new/c.md" {
+		t.Fatalf("expected new/c.md to be added, got %v", m.files)
+	}
+	if m.err != nil {
+		t.Errorf("unexpected error: %v", m.err)
+	}
+	if !m.isChanged(&m.files[1].sections[0].Items[0]) {
+		t.Error("expected items of a new file to be flagged")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	m.applyFileChanges(readChanged([]string{path}, nil))
+	if len(m.files) != 1 {
+		t.Errorf("expected removed file to leave the tree, got %d files", len(m.files))
+	}
+	if len(m.changed) != 0 {
+		t.Errorf("expected flags of the removed file to be dropped, got %v", m.changed)
+	}
+}
+
+func TestMissingFileWaitsForReappearance(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] A\n")
+	m.width, m.height = 80, 20
+	if err := os.Remove(m.filePath); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+	if m.err != nil {
+		t.Errorf("expected no error while waiting, got %v", m.err)
+	}
+	if !m.files[0].missing || len(m.nodes) != 2 {
+		t.Fatalf("expected the last tree to stay while missing, got missing=%v and %d nodes", m.files[0].missing, len(m.nodes))
+	}
+	if !strings.Contains(m.View(), "File removed, waiting...") {
+		t.Error("expected the waiting banner")
+	}
+
+	m.cursor = 1
+	m.toggleItem()
+	if !errors.Is(m.err, errMissing) {
+		t.Errorf("expected edits to be refused while missing, got %v", m.err)
+	}
+	if _, err := os.Stat(m.filePath); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("a refused edit must not recreate the file")
+	}
+
+	writeFiles(t, filepath.Dir(m.filePath), map[string]string{"todo.md": "## Work\n- [x] A\n"})
+	m.err = nil
+	m.applyFileChanges(readChanged([]string{m.filePath}, nil))
+	if m.files[0].missing || strings.Contains(m.View(), "waiting") {
+		t.Error("expected the file to resume when it reappears")
+	}
+	if !m.sections[0].Items[0].Completed {
+		t.Error("expected the reappeared content to be shown")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tagCount is a row in the tag panel.
+type tagCount struct {
+	tag  string
+	open int
+	done int
+}
+
+// matcher returns the item filter for the current view, or nil when every
+// item is shown. The search query and the tag filter must both match.
+func (m model) matcher() func(*TodoItem) bool {
+	if m.query == "" && len(m.tagFilter) == 0 {
+		return nil
+	}
+	query := strings.ToLower(m.query)
+	selected := m.selectedTags()
+	all := m.tagsAll
+	return func(item *TodoItem) bool {
+		if query != "" && !matchesQuery(item, query) {
+			return false
+		}
+		return len(selected) == 0 || matchesTags(item, selected, all)
+	}
+}
+
+// hasTag reports whether the item carries tag or a tag nested under it, so
+// that selecting "api" also matches "api/v2".
+func hasTag(item *TodoItem, tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag || strings.HasPrefix(t, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTags reports whether the item has all (AND) or any (OR) of tags.
+func matchesTags(item *TodoItem, tags []string, all bool) bool {
+	for _, tag := range tags {
+		has := hasTag(item, tag)
+		if all && !has {
+			return false
+		}
+		if !all && has {
+			return true
+		}
+	}
+	return all
+}
+
+// countTags returns every tag used in sections, including the parents of
+// hierarchical tags, with open and done item counts, sorted by name.
+// Cancelled items are not counted.
+func countTags(sections []TodoSection) []tagCount {
+	counts := make(map[string]*tagCount)
+	var walk func(sections []TodoSection)
+	walk = func(sections []TodoSection) {
+		for _, s := range sections {
+			eachItem(s.Items, func(item *TodoItem) {
+				if item.Status == StatusCancelled {
+					return
+				}
+				// An item tagged both api and api/v2 counts once under api
+				seen := make(map[string]bool)
+				for _, tag := range item.Tags {
+					parts := strings.Split(tag, "/")
+					for i := range parts {
+						name := strings.Join(parts[:i+1], "/")
+						if seen[name] {
+							continue
+						}
+						seen[name] = true
+						c := counts[name]
+						if c == nil {
+							c = &tagCount{tag: name}
+							counts[name] = c
+						}
+						if item.Completed {
+							c.done++
+						} else {
+							c.open++
+						}
+					}
+				}
+			})
+			walk(s.Subsections)
+		}
+	}
+	walk(sections)
+
+	tags := make([]tagCount, 0, len(counts))
+	for _, c := range counts {
+		tags = append(tags, *c)
+	}
+	slices.SortFunc(tags, func(a, b tagCount) int { return strings.Compare(a.tag, b.tag) })
+	return tags
+}
+
+// selectedTags returns the tag filter as a sorted slice.
+func (m model) selectedTags() []string {
+	tags := make([]string, 0, len(m.tagFilter))
+	for tag := range m.tagFilter {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// tagFilterLabel describes the tag filter for the header, e.g. "api & ssmd".
+func (m model) tagFilterLabel() string {
+	sep := " | "
+	if m.tagsAll {
+		sep = " & "
+	}
+	return strings.Join(m.selectedTags(), sep)
+}
+
+// updateTags handles keys while the tag panel is open. Selections apply to
+// the tree immediately.
+func (m model) updateTags(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "enter", "q", "t":
+		m.mode = modeNormal
+
+	case "up", "k":
+		m.tagCursor = max(m.tagCursor-1, 0)
+
+	case "down", "j":
+		m.tagCursor = min(m.tagCursor+1, max(len(m.tags)-1, 0))
+
+	case " ", "x":
+		if m.tagCursor < len(m.tags) {
+			tag := m.tags[m.tagCursor].tag
+			if m.tagFilter[tag] {
+				delete(m.tagFilter, tag)
+			} else {
+				m.tagFilter[tag] = true
+			}
+			m.refreshNodes()
+		}
+
+	case "tab":
+		m.tagsAll = !m.tagsAll
+		m.refreshNodes()
+
+	case "c":
+		m.tagFilter = make(map[string]bool)
+		m.refreshNodes()
+	}
+	return m, nil
+}
+
+// renderTags renders the tag panel into b, scrolled so the panel cursor stays
+// visible, and returns the number of lines written.
+func (m model) renderTags(b *strings.Builder, height int) int {
+	w := max(m.listWidth(), 10)
+	mode := "any (OR)"
+	if m.tagsAll {
+		mode = "all (AND)"
+	}
+	countStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	b.WriteString(lipgloss.NewStyle().MaxWidth(w).Render(countStyle.Render(" match " + mode)))
+	b.WriteString("\n")
+
+	rows := height - 1
+	start := max(m.tagCursor-rows+1, 0)
+	end := min(start+rows, len(m.tags))
+	for i := start; i < end; i++ {
+		t := m.tags[i]
+		check := "[ ]"
+		if m.tagFilter[t.tag] {
+			check = "[x]"
+		}
+		line := check + " " + t.tag + " " + countStyle.Render(fmt.Sprintf("%d open, %d done", t.open, t.done))
+		if i == m.tagCursor {
+			b.WriteString(lipgloss.NewStyle().Background(lipgloss.Color("#3A3A3A")).MaxWidth(w).Render(">" + line))
+		} else {
+			b.WriteString(lipgloss.NewStyle().MaxWidth(w).Render(" " + line))
+		}
+		b.WriteString("\n")
+	}
+	return end - start + 1
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCountTagsIncludesParents(t *testing.T) {
+	sections := Parse("## Work\n- [ ] A [api/v2]\n- [x] B [api] [api/v1]\n### Sub\n- [ ] C [ssmd]")
+
+	got := countTags(sections)
+	want := []tagCount{
+		{tag: "api", open: 1, done: 1},
+		{tag: "api/v1", open: 0, done: 1},
+		{tag: "api/v2", open: 1, done: 0},
+		{tag: "ssmd", open: 1, done: 0},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("countTags = %+v, want %+v", got, want)
+	}
+}
+
+func TestTagFilterAndOr(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] A [api/v2] [ssmd]\n- [ ] B [api]\n- [ ] C [ssmd]\n- [ ] D\n")
+
+	titles := func() []string {
+		var out []string
+		for _, n := range m.nodes {
+			if !n.isSection {
+				out = append(out, n.item.Title)
+			}
+		}
+		return out
+	}
+
+	m.tagFilter["api"] = true
+	m.refreshNodes()
+	if got := titles(); !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("api = %v, want [A B]", got)
+	}
+
+	m.tagFilter["ssmd"] = true
+	m.refreshNodes()
+	if got := titles(); !slices.Equal(got, []string{"A", "B", "C"}) {
+		t.Errorf("api OR ssmd = %v, want [A B C]", got)
+	}
+
+	m.tagsAll = true
+	m.refreshNodes()
+	if got := titles(); !slices.Equal(got, []string{"A"}) {
+		t.Errorf("api AND ssmd = %v, want [A]", got)
+	}
+}
+
+func TestHideDoneKeepsBadgeCounts(t *testing.T) {
+	m := newTestModel(t, "## Mixed\n- [x] Done A\n- [ ] Open B\n### Finished\n- [x] Done C\n## Empty\n")
+	m.hideDone = true
+	m.refreshNodes()
+
+	var got []string
+	for _, n := range m.nodes {
+		if n.isSection {
+			got = append(got, "#"+n.key)
+		} else {
+			got = append(got, n.item.Title)
+		}
+	}
+	want := []string{"#Mixed", "Open B", "#Empty"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("nodes = %v, want %v", got, want)
+	}
+
+	done, total := sectionStats(m.nodes[0].section)
+	if done != 2 || total != 3 {
+		t.Errorf("badge = [%d/%d], want [2/3]", done, total)
+	}
+}
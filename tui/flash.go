@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// flashColor highlights added or toggled items, like the orange flash in the
+// menu bar app.
+const flashColor = "#FFA94D"
+
+// flashExpiredMsg is sent periodically while timed change highlights are shown.
+type flashExpiredMsg struct{}
+
+// changeKeys returns the change-tracking key of every item in sections, built
+// the same way as the Swift DirectoryWatcher: "file:title#occurrence", where
+// occurrence counts earlier items with the same title in tree order.
+func changeKeys(fileName string, sections []TodoSection) map[*TodoItem]string {
+	keys := make(map[*TodoItem]string)
+	counts := make(map[string]int)
+	var walk func(sections []TodoSection)
+	walk = func(sections []TodoSection) {
+		for i := range sections {
+			s := &sections[i]
+			eachItem(s.Items, func(item *TodoItem) {
+				base := fileName + ":" + item.Title
+				keys[item] = fmt.Sprintf("%s#%d", base, counts[base])
+				counts[base]++
+			})
+			walk(s.Subsections)
+		}
+	}
+	walk(sections)
+	return keys
+}
+
+// noteChanges flags the items in sections, the new snapshot of file fi, that
+// were added or toggled compared to the snapshot currently shown. Files are
+// loaded before the TUI starts, so every item not seen before counts as
+// added, including the first items of an empty file and those of a file that
+// just appeared. Flags accumulate until acknowledged or expired; flags for
+// items that no longer exist are dropped.
+func (m *model) noteChanges(fi int, sections []TodoSection) {
+	prefix := m.files[fi].name + ":"
+	type mark struct {
+		status    Status
+		completed bool
+	}
+	prev := make(map[string]mark, len(m.itemKeys))
+	live := make(map[string]bool)
+	for item, key := range m.itemKeys {
+		prev[key] = mark{item.Status, item.Completed}
+		if !strings.HasPrefix(key, prefix) {
+			live[key] = true
+		}
+	}
+
+	now := time.Now()
+	for item, key := range changeKeys(m.files[fi].name, sections) {
+		live[key] = true
+		if was, ok := prev[key]; !ok || was != (mark{item.Status, item.Completed}) {
+			m.changed[key] = now
+		}
+	}
+	for key := range m.changed {
+		if !live[key] {
+			delete(m.changed, key)
+		}
+	}
+}
+
+// isChanged reports whether item is flagged as changed and not yet acknowledged.
+func (m model) isChanged(item *TodoItem) bool {
+	key, ok := m.itemKeys[item]
+	if !ok {
+		return false
+	}
+	_, changed := m.changed[key]
+	return changed
+}
+
+// navigationKeys are the keys that move the cursor. Landing on a changed item
+// with one of them acknowledges it.
+var navigationKeys = map[string]bool{
+	"up": true, "k": true, "down": true, "j": true,
+	"pgup": true, "pgdown": true, "home": true, "end": true,
+	"n": true, "N": true,
+}
+
+// acknowledgeCursor clears the change flag of the item under the cursor.
+func (m *model) acknowledgeCursor() {
+	if m.cursor < len(m.nodes) && !m.nodes[m.cursor].isSection {
+		delete(m.changed, m.itemKeys[m.nodes[m.cursor].item])
+	}
+}
+
+// flashTick schedules the next expiry check when highlights are timed. Only
+// one check is pending at a time; flashExpiredMsg clears flashArmed before
+// scheduling the next.
+func (m *model) flashTick() tea.Cmd {
+	if m.flashFor <= 0 || len(m.changed) == 0 || m.flashArmed {
+		return nil
+	}
+	m.flashArmed = true
+	return tea.Tick(m.flashFor, func(time.Time) tea.Msg {
+		return flashExpiredMsg{}
+	})
+}
+
+// expireChanges drops change flags older than the flash duration.
+func (m *model) expireChanges() {
+	for key, at := range m.changed {
+		if time.Since(at) >= m.flashFor {
+			delete(m.changed, key)
+		}
+	}
+}
+
+// jumpChange moves the cursor to the next changed item after the cursor,
+// wrapping around and expanding its collapsed ancestors, parent items
+// included.
+func (m *model) jumpChange() {
+	if len(m.changed) == 0 {
+		return
+	}
+
+	type target struct {
+		file      int
+		item      *TodoItem
+		ancestors []string
+	}
+	var targets []target
+	var walk func(fi int, sections []TodoSection, prefix string, ancestors []string)
+	walk = func(fi int, sections []TodoSection, prefix string, ancestors []string) {
+		for i := range sections {
+			s := &sections[i]
+			key := sectionKey(prefix, s.Heading)
+			path := append(slices.Clone(ancestors), key)
+			var walkItems func(items []TodoItem, parent string, path []string)
+			walkItems = func(items []TodoItem, parent string, path []string) {
+				for j := range items {
+					if m.isChanged(&items[j]) {
+						targets = append(targets, target{file: fi, item: &items[j], ancestors: path})
+					}
+					fold := foldKey(parent, items[j].Title)
+					walkItems(items[j].Children, fold, append(slices.Clone(path), fold))
+				}
+			}
+			walkItems(s.Items, key, path)
+			walk(fi, s.Subsections, key, path)
+		}
+	}
+	for fi, f := range m.files {
+		var ancestors []string
+		if m.multi {
+			ancestors = []string{f.name}
+		}
+		walk(fi, f.sections, m.filePrefix(fi), ancestors)
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	currentFile, current := 0, 0
+	if m.cursor < len(m.nodes) {
+		currentFile, current = m.cursorFile(), m.nodes[m.cursor].line()
+	}
+	next := targets[0]
+	for _, t := range targets {
+		if cmp.Or(cmp.Compare(t.file, currentFile), cmp.Compare(t.item.Line, current)) > 0 {
+			next = t
+			break
+		}
+	}
+
+	for _, key := range next.ancestors {
+		delete(m.collapsed, key)
+	}
+	m.refreshNodes()
+	m.selectLine(m.files[next.file].path, next.item.Line)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestChangeKeysCountOccurrences(t *testing.T) {
+	sections := Parse("## A\n- [ ] Same\n### B\n- [ ] Same\n- [ ] Other")
+
+	keys := changeKeys("todo.md", sections)
+	var got []string
+	for _, key := range keys {
+		got = append(got, key)
+	}
+	slices.Sort(got)
+	want := []string{"todo.md:Other#0", "todo.md:Same#0", "todo.md:Same#1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestNoteChangesFlagsAddedAndToggled(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] Keep\n- [ ] Toggle\n- [ ] Remove\n")
+
+	updated := Parse("## Tasks\n- [ ] Keep\n- [x] Toggle\n- [ ] New\n")
+	m.noteChanges(0, updated)
+	m.setSnapshot(0, updated, "")
+
+	var flagged []string
+	for _, n := range m.nodes {
+		if !n.isSection && m.isChanged(n.item) {
+			flagged = append(flagged, n.item.Title)
+		}
+	}
+	if !slices.Equal(flagged, []string{"Toggle", "New"}) {
+		t.Errorf("flagged = %v, want [Toggle New]", flagged)
+	}
+
+	// Visiting an item acknowledges it
+	m.cursor = 2
+	m.acknowledgeCursor()
+	if len(m.changed) != 1 {
+		t.Errorf("expected 1 unacknowledged change, got %d", len(m.changed))
+	}
+
+	m.jumpChange()
+	if m.nodes[m.cursor].item.Title != "New" {
+		t.Errorf("jumped to %q, want New", m.nodes[m.cursor].item.Title)
+	}
+
+	// Timed highlights expire
+	m.flashFor = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	m.expireChanges()
+	if len(m.changed) != 0 {
+		t.Errorf("expected changes to expire, got %d", len(m.changed))
+	}
+}
+
+func pressKey(m model, key string) model {
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	return updated.(model)
+}
+
+func TestJumpChangeKeepsHighlightUntilMovingOn(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] A\n- [ ] B\n- [ ] C\n")
+	updated := Parse("## Tasks\n- [x] A\n- [ ] B\n- [x] C\n")
+	m.noteChanges(0, updated)
+	m.setSnapshot(0, updated, "")
+
+	m = pressKey(m, ".")
+	if cursorTitle(m) != "A" || !m.isChanged(m.nodes[m.cursor].item) {
+		t.Fatalf("expected to land on A with its highlight kept, got %q", cursorTitle(m))
+	}
+	m = pressKey(m, ".")
+	if cursorTitle(m) != "C" || len(m.changed) != 1 {
+		t.Errorf("expected A acknowledged and the cursor on C, got %q with %d changes", cursorTitle(m), len(m.changed))
+	}
+
+	// Navigating onto a changed item acknowledges it
+	m = pressKey(m, "k")
+	m = pressKey(m, "j")
+	if len(m.changed) != 0 {
+		t.Errorf("expected C acknowledged, got %d changes", len(m.changed))
+	}
+}
+
+func TestNoteChangesFlagsFirstItems(t *testing.T) {
+	m := newTestModel(t, "## Tasks\nnothing yet\n")
+	updated := Parse("## Tasks\n- [ ] First\n")
+	m.noteChanges(0, updated)
+	if len(m.changed) != 1 {
+		t.Errorf("expected the first item of the file to be flagged, got %v", m.changed)
+	}
+}
+
+func TestFlashTickSinglePending(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] A\n")
+	m.flashFor = time.Minute
+	path := m.filePath
+	change := func(content string) tea.Cmd {
+		sections := Parse(content)
+		updated, cmd := m.Update(FilesChangedMsg{FileUpdatedMsg{Path: path, Sections: sections, Hash: contentHash([]byte(content))}})
+		m = updated.(model)
+		return cmd
+	}
+
+	if change("## Tasks\n- [x] A\n") == nil {
+		t.Fatal("expected an expiry check to be scheduled")
+	}
+	if change("## Tasks\n- [x] A\n- [ ] B\n") != nil {
+		t.Error("expected no second check while one is pending")
+	}
+	updated, cmd := m.Update(flashExpiredMsg{})
+	m = updated.(model)
+	if cmd == nil || !m.flashArmed {
+		t.Error("expected the expiry check to re-arm while changes remain")
+	}
+}
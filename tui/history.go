@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// maxHistory caps the number of edits kept for undo.
+const maxHistory = 100
+
+// errHistoryConflict reports that the file no longer holds the content an
+// undo or redo expects, so restoring would overwrite someone else's change.
+var errHistoryConflict = errors.New("file changed since that edit, not restoring")
+
+// editRecord holds the file contents around one TUI-originated edit.
+type editRecord struct {
+	path    string
+	before  string
+	after   string
+	archive *archiveWrite // archive.md as changed by the same edit, or nil
+}
+
+// recordEdit pushes an edit onto the undo stack and clears the redo stack.
+func (m *model) recordEdit(rec editRecord) {
+	m.undoStack = append(m.undoStack, rec)
+	if len(m.undoStack) > maxHistory {
+		m.undoStack = m.undoStack[len(m.undoStack)-maxHistory:]
+	}
+	m.redoStack = nil
+}
+
+// undo restores the file to its content before the most recent TUI edit.
+// Items archived to archive.md are taken out of it again.
+func (m *model) undo() {
+	if len(m.undoStack) == 0 {
+		return
+	}
+	rec := m.undoStack[len(m.undoStack)-1]
+	if w := rec.archive; w != nil && !w.holds(w.after, true) {
+		m.err = errHistoryConflict
+		return
+	}
+	if !m.restore(rec.path, rec.after, rec.before) {
+		return
+	}
+	if w := rec.archive; w != nil {
+		if err := w.revert(); err != nil {
+			m.err = err
+		}
+	}
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	m.redoStack = append(m.redoStack, rec)
+}
+
+// redo reapplies the most recently undone edit.
+func (m *model) redo() {
+	if len(m.redoStack) == 0 {
+		return
+	}
+	rec := m.redoStack[len(m.redoStack)-1]
+	if w := rec.archive; w != nil && !w.holds(w.before, w.existed) {
+		m.err = errHistoryConflict
+		return
+	}
+	if !m.restore(rec.path, rec.before, rec.after) {
+		return
+	}
+	if w := rec.archive; w != nil {
+		if err := w.apply(); err != nil {
+			m.err = err
+		}
+	}
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+	m.undoStack = append(m.undoStack, rec)
+}
+
+// restore replaces the content of the file at path with to, provided the file
+// still holds exactly from. It reports whether the file was written.
+func (m *model) restore(path, from, to string) bool {
+	_, _, ok := m.writeEdit(path, func(content string) (string, error) {
+		if content != from {
+			return "", errHistoryConflict
+		}
+		return to, nil
+	})
+	return ok
+}
+
+// holds reports whether archive.md currently has content, or is absent when
+// exists is false.
+func (w *archiveWrite) holds(content string, exists bool) bool {
+	data, err := os.ReadFile(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return !exists
+	}
+	return err == nil && exists && string(data) == content
+}
+
+// revert puts archive.md back as it was before archiving, removing it if
+// archiving created it.
+func (w *archiveWrite) revert() error {
+	if !w.existed {
+		return os.Remove(w.path)
+	}
+	return writeFileAtomic(w.path, []byte(w.before))
+}
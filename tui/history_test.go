@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestModel opens content as a single file, with UI state kept in a
+// temporary directory rather than the user's own.
+func newTestModel(t *testing.T, content string) model {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sections, hash, err := ReadAndParse(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return initialModel(path, "todo.md", sections, hash)
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUndoRedo(t *testing.T) {
+	original := "## Tasks\n- [ ] Task\n"
+	m := newTestModel(t, original)
+
+	m.cursor = 1
+	m.toggleItem()
+	toggled := readFile(t, m.filePath)
+	if toggled != "## Tasks\n- [x] Task\n" {
+		t.Fatalf("unexpected content after toggle: %q", toggled)
+	}
+
+	m.undo()
+	if got := readFile(t, m.filePath); got != original {
+		t.Errorf("after undo = %q, want %q", got, original)
+	}
+	if m.sections[0].Items[0].Completed {
+		t.Error("expected model to show the undone state")
+	}
+
+	m.redo()
+	if got := readFile(t, m.filePath); got != toggled {
+		t.Errorf("after redo = %q, want %q", got, toggled)
+	}
+
+	// A new edit clears the redo stack
+	m.undo()
+	m.addItem("Tasks", "Another")
+	if len(m.redoStack) != 0 {
+		t.Errorf("expected empty redo stack, got %d entries", len(m.redoStack))
+	}
+}
+
+func TestUndoRefusedAfterExternalChange(t *testing.T) {
+	m := newTestModel(t, "## Tasks\n- [ ] Task\n")
+
+	m.cursor = 1
+	m.toggleItem()
+
+	external := "## Tasks\n- [x] Task\n- [ ] From an agent\n"
+	if err := os.WriteFile(m.filePath, []byte(external), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The watcher delivers the external change before the undo
+	sections, hash, err := ReadAndParse(m.filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.setSnapshot(0, sections, hash)
+
+	m.undo()
+	if !errors.Is(m.err, errHistoryConflict) {
+		t.Errorf("err = %v, want errHistoryConflict", m.err)
+	}
+	if got := readFile(t, m.filePath); got != external {
+		t.Errorf("file was overwritten: %q", got)
+	}
+	if len(m.undoStack) != 1 {
+		t.Errorf("expected undo entry to be kept, got %d", len(m.undoStack))
+	}
+}
+
+func TestUndoArchiveToFile(t *testing.T) {
+	original := "## Work\n- [x] Shipped\n- [ ] Open\n"
+	m := newTestModel(t, original)
+	m.archive.ToFile = true
+	archivePath := filepath.Join(filepath.Dir(m.filePath), archiveFileName)
+
+	m.archiveItems(0)
+	archived := readFile(t, archivePath)
+
+	m.undo()
+	if got := readFile(t, m.filePath); got != original {
+		t.Errorf("after undo = %q, want %q", got, original)
+	}
+	if _, err := os.Stat(archivePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("archive.md created by the archive should be removed, stat err %v", err)
+	}
+
+	m.redo()
+	if got := readFile(t, archivePath); got != archived {
+		t.Errorf("after redo archive = %q, want %q", got, archived)
+	}
+
+	// An archive.md changed since is not overwritten
+	if err := os.WriteFile(archivePath, []byte("# Archive\n\nedited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.undo()
+	if !errors.Is(m.err, errHistoryConflict) {
+		t.Errorf("err = %v, want errHistoryConflict", m.err)
+	}
+	if got := readFile(t, m.filePath); got != "## Work\n- [ ] Open\n" {
+		t.Errorf("file was restored despite the conflict: %q", got)
+	}
+}
+
+func TestUndoArchiveKeepsExistingArchive(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [x] Shipped\n")
+	m.archive.ToFile = true
+	archivePath := filepath.Join(filepath.Dir(m.filePath), archiveFileName)
+	existing := "# Archive\n\n## Old\n- [x] Earlier\n"
+	if err := os.WriteFile(archivePath, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m.archiveItems(0)
+	m.undo()
+	if got := readFile(t, archivePath); got != existing {
+		t.Errorf("archive after undo = %q, want %q", got, existing)
+	}
+}
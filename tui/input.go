@@ -0,0 +1,78 @@
+package main
+
+import (
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lineInput is a minimal single-line text editor used for inline prompts.
+type lineInput struct {
+	value []rune
+	pos   int
+}
+
+// newLineInput returns an input pre-filled with value and the cursor at the end.
+func newLineInput(value string) lineInput {
+	r := []rune(value)
+	return lineInput{value: r, pos: len(r)}
+}
+
+// Value returns the current text.
+func (in lineInput) Value() string {
+	return string(in.value)
+}
+
+// Update applies an editing key to the input.
+func (in *lineInput) Update(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		runes := msg.Runes
+		if msg.Type == tea.KeySpace {
+			runes = []rune{' '}
+		}
+		in.value = append(in.value[:in.pos], append(runes, in.value[in.pos:]...)...)
+		in.pos += len(runes)
+	case tea.KeyBackspace:
+		if in.pos > 0 {
+			in.value = append(in.value[:in.pos-1], in.value[in.pos:]...)
+			in.pos--
+		}
+	case tea.KeyDelete:
+		if in.pos < len(in.value) {
+			in.value = append(in.value[:in.pos], in.value[in.pos+1:]...)
+		}
+	case tea.KeyLeft:
+		in.pos = max(in.pos-1, 0)
+	case tea.KeyRight:
+		in.pos = min(in.pos+1, len(in.value))
+	case tea.KeyHome, tea.KeyCtrlA:
+		in.pos = 0
+	case tea.KeyEnd, tea.KeyCtrlE:
+		in.pos = len(in.value)
+	case tea.KeyCtrlU:
+		in.value = in.value[in.pos:]
+		in.pos = 0
+	case tea.KeyCtrlW:
+		start := in.pos
+		for start > 0 && unicode.IsSpace(in.value[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(in.value[start-1]) {
+			start--
+		}
+		in.value = append(in.value[:start], in.value[in.pos:]...)
+		in.pos = start
+	}
+}
+
+// View renders the text with a block cursor at the insertion point.
+func (in lineInput) View() string {
+	cursorStyle := lipgloss.NewStyle().Reverse(true)
+	before := string(in.value[:in.pos])
+	if in.pos < len(in.value) {
+		return before + cursorStyle.Render(string(in.value[in.pos])) + string(in.value[in.pos+1:])
+	}
+	return before + cursorStyle.Render(" ")
+}
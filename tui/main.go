@@ -2,44 +2,177 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+const usage = `Usage: todoagent-tui [flags] <file.md | dir | pattern>...
+       todoagent-tui archive [flags] <file.md>
+`
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: todoagent-tui <file.md>\n")
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		os.Exit(runArchive(os.Args[2:]))
 	}
 
-	filePath := os.Args[1]
+	flags := flag.NewFlagSet("todoagent-tui", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	archive := archiveFlags(flags)
+	hideDone := flags.Bool("hide-done", false, "start with completed items hidden (overrides the saved setting)")
+	flashFor := flags.Duration("flash", 0, "how long changed items stay highlighted (0 keeps them until visited)")
+	var watch watchOptions
+	flags.DurationVar(&watch.poll, "poll", 0, "poll for changes at this interval instead of using file system notifications")
+	flags.DurationVar(&watch.debounce, "debounce", defaultDebounce, "how long to wait for a burst of file changes to settle")
+	var symbols map[byte]Status
+	symbolsFlag(flags, &symbols)
+	flags.Parse(os.Args[1:])
 
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
+	if flags.NArg() < 1 {
+		flags.Usage()
 		os.Exit(1)
 	}
 
-	if _, err := os.Stat(absPath); err != nil {
+	m, err := loadModel(flags.Args(), symbols)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	m.archive = *archive
+	if *hideDone {
+		m.hideDone = true
+	}
+	m.flashFor = *flashFor
+	m.refreshNodes()
 
-	sections, err := ReadAndParse(absPath)
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	watch.symbols = symbols
+	watcher := StartWatcher(m.src, p.Send, watch)
+	final, err := p.Run()
+	watcher.Close()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if fm, ok := final.(model); ok {
+		if err := fm.saveState(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving UI state: %v\n", err)
+		}
+	}
+}
 
-	fileName := filepath.Base(absPath)
-	m := initialModel(absPath, fileName, sections)
+// loadModel builds the initial model for the paths on the command line. A
+// single file is shown on its own; directories, patterns and several files are
+// shown with one top-level node per file. Checkboxes are parsed with symbols.
+func loadModel(args []string, symbols map[byte]Status) (model, error) {
+	if len(args) == 1 {
+		if info, err := os.Stat(args[0]); err == nil && !info.IsDir() {
+			absPath, err := resolveFile(args[0])
+			if err != nil {
+				return model{}, err
+			}
+			sections, hash, err := ReadAndParse(absPath, symbols)
+			if err != nil {
+				return model{}, fmt.Errorf("reading file: %w", err)
+			}
+			m := initialModel(absPath, filepath.Base(absPath), sections, hash)
+			m.symbols = symbols
+			return m, nil
+		}
+	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	src, err := resolveSources(args)
+	if err != nil {
+		return model{}, err
+	}
+	paths, err := src.expand()
+	if err != nil {
+		return model{}, err
+	}
+	files, err := loadFiles(src, paths, symbols)
+	if err != nil {
+		return model{}, fmt.Errorf("reading file: %w", err)
+	}
+	roots := slices.Concat(src.files, src.dirs, src.globs)
+	m := initialMultiModel(strings.Join(roots, string(os.PathListSeparator)), strings.Join(args, " "), src, files)
+	m.symbols = symbols
+	return m, nil
+}
+
+// runArchive implements the archive subcommand and returns the exit code.
+func runArchive(args []string) int {
+	flags := flag.NewFlagSet("archive", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: todoagent-tui archive [flags] <file.md>\n")
+		flags.PrintDefaults()
+	}
+	opts := archiveFlags(flags)
+	symbolsFlag(flags, &opts.Symbols)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	absPath, err := resolveFile(flags.Arg(0))
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+
+	updated, archive, count, err := archiveCompleted(absPath, string(data), *opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error archiving: %v\n", err)
+		return 1
+	}
+	if count == 0 {
+		fmt.Println("Nothing to archive")
+		return 0
+	}
+	if err := writeFileAtomic(absPath, []byte(updated)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+		return 1
+	}
+	if archive != nil {
+		if err := archive.apply(); err != nil {
+			// Put the items back rather than lose them
+			writeFileAtomic(absPath, data)
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", archive.path, err)
+			return 1
+		}
+	}
+
+	dest := "## " + opts.Heading
+	if opts.ToFile {
+		dest = filepath.Join(filepath.Dir(absPath), archiveFileName)
+	}
+	fmt.Printf("Archived %d item(s) to %s\n", count, dest)
+	return 0
+}
+
+// resolveFile makes path absolute and checks that it exists.
+func resolveFile(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving path: %w", err)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return "", err
 	}
+	return absPath, nil
 }
@@ -2,8 +2,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -21,6 +26,9 @@ var pastelColors = []lipgloss.Color{
 	lipgloss.Color("#BF80E6"), // orchid
 }
 
+// missingColor dims the rows of files that were deleted on disk.
+const missingColor = "#555555"
+
 // node represents a single navigable row in the TUI — either a section heading or a todo item.
 type node struct {
 	isSection bool
@@ -29,13 +37,49 @@ type node struct {
 	item      *TodoItem
 	colorIdx  int
 	key       string // full path for collapse tracking, e.g. "SSMD/Active"
+	fold      string // collapse key of an item with children
+}
+
+// line returns the source line of the node's heading or checkbox.
+func (n node) line() int {
+	if n.isSection {
+		return n.section.Line
+	}
+	return n.item.Line
+}
+
+// isFile reports whether a section node stands for a whole file.
+func (n node) isFile() bool {
+	return n.isSection && n.section.Level == 0
 }
 
+// inputMode selects what the inline prompt is collecting text for.
+type inputMode int
+
+const (
+	modeNormal inputMode = iota
+	modeAdd
+	modeEdit
+	modeMove
+	modeSearch
+	modeTags
+)
+
+// Field positions in the edit form; detail fields follow the tags field.
+const (
+	formTitle = iota
+	formTags
+	formFirstDetail
+)
+
 // model is the Bubble Tea model for the TodoAgent TUI.
 type model struct {
-	filePath  string
-	fileName  string
-	sections  []TodoSection
+	filePath  string        // file, directory or paths the TUI was opened on
+	fileName  string        // name shown in the header
+	files     []todoFile    // every file shown, in display order
+	src       sources       // what is watched and which new files belong in the tree
+	multi     bool          // show each file as a top-level node above its sections
+	sections  []TodoSection // the tree shown, composed from files
 	nodes     []node
 	cursor    int
 	collapsed map[string]bool
@@ -43,24 +87,154 @@ type model struct {
 	height    int
 	scroll    int
 	err       error
+
+	pollReason error // why the watcher polls instead of using notifications
+
+	mode        inputMode
+	input       lineInput
+	inputTarget string // section key the prompt applies to
+
+	form      []lineInput // title, tags and detail fields while editing an item
+	formFocus int
+	editPath  string // file of the item being edited or moved
+	editLine  int    // source line of the item being edited or moved
+	editHash  string // snapshot hash of editPath when editLine was taken
+
+	targets      []moveTarget // sections offered when moving an item
+	targetCursor int
+
+	archive archiveOptions
+	symbols map[byte]Status // checkbox symbols to parse with; nil means the defaults
+
+	undoStack []editRecord
+	redoStack []editRecord
+
+	query string // active search filter
+
+	tags      []tagCount      // every tag in the file, shown in the tag panel
+	tagCursor int             // selected row in the tag panel
+	tagFilter map[string]bool // tags the view is narrowed to
+	tagsAll   bool            // require all selected tags (AND) instead of any (OR)
+
+	hideDone bool // hide completed items and fully completed sections
+	byDue    bool // list items from every section soonest due first
+
+	showDetails bool // show the detail pane for the node under the cursor
+
+	itemKeys   map[*TodoItem]string // change-tracking key of every item in sections
+	changed    map[string]time.Time // unacknowledged changes by key, with when they were seen
+	flashFor   time.Duration        // how long changes stay highlighted; 0 means until visited
+	flashArmed bool                 // an expiry check is pending
 }
 
-// initialModel creates the initial model with parsed sections.
-func initialModel(filePath, fileName string, sections []TodoSection) model {
+// moveTarget is a section offered in the move-to-section picker.
+type moveTarget struct {
+	key     string
+	heading string
+	depth   int
+}
+
+// errConflict reports that the file changed on disk after the snapshot the TUI
+// is showing, so an edit was refused rather than overwriting the other change.
+var errConflict = errors.New("file changed on disk, edit discarded")
+
+// errMissing reports that an edit was refused because its file was deleted and
+// the TUI is showing the last known content while waiting for it to return.
+var errMissing = errors.New("file removed, edits resume when it reappears")
+
+// initialModel creates the initial model for a single file with parsed sections.
+func initialModel(filePath, fileName string, sections []TodoSection, hash string) model {
+	file := todoFile{path: filePath, name: fileName, sections: sections, hash: hash}
+	return newModel(filePath, fileName, sources{files: []string{filePath}}, []todoFile{file}, false)
+}
+
+// initialMultiModel creates the initial model for several files, each shown as
+// a top-level node. filePath identifies what the TUI was opened on.
+func initialMultiModel(filePath, fileName string, src sources, files []todoFile) model {
+	return newModel(filePath, fileName, src, files, true)
+}
+
+// newModel creates the initial model shared by single and multi-file views.
+func newModel(filePath, fileName string, src sources, files []todoFile, multi bool) model {
 	m := model{
 		filePath:  filePath,
 		fileName:  fileName,
-		sections:  sections,
+		files:     files,
+		src:       src,
+		multi:     multi,
 		collapsed: make(map[string]bool),
+		archive:   archiveOptions{Heading: defaultDoneHeading},
+		tagFilter: make(map[string]bool),
+
+		showDetails: true,
+		changed:     make(map[string]time.Time),
 	}
+	m.compose()
 
-	// Set default collapsed state: sections with AllCompleted are collapsed by default
-	setDefaultCollapsed(sections, "", m.collapsed)
+	// Restore the previous session's state for this file, if any; otherwise
+	// sections with AllCompleted are collapsed by default
+	if st, err := loadState(filePath); err == nil && st.Path == filePath {
+		m.applyState(st)
+		return m
+	}
+	setDefaultCollapsed(m.sections, "", m.collapsed)
 
-	m.nodes = flatten(sections, m.collapsed)
+	m.refreshNodes()
 	return m
 }
 
+// compose rebuilds the displayed tree and the change-tracking keys from the
+// files. A single file is shown as its own sections; with several files each
+// becomes a level 0 section named after the file. The file nodes share the
+// files' section slices, so pointers into the tree point into the files.
+func (m *model) compose() {
+	m.itemKeys = make(map[*TodoItem]string)
+	for _, f := range m.files {
+		maps.Copy(m.itemKeys, changeKeys(f.name, f.sections))
+	}
+	if !m.multi {
+		m.sections = m.files[0].sections
+		return
+	}
+	m.sections = make([]TodoSection, len(m.files))
+	for i, f := range m.files {
+		m.sections[i] = TodoSection{Heading: f.name, Subsections: f.sections}
+		done, total := sectionStats(&m.sections[i])
+		m.sections[i].AllCompleted = total > 0 && done == total
+	}
+}
+
+// fileIndex returns the index of the file a section key belongs to, or -1.
+func (m model) fileIndex(key string) int {
+	if !m.multi {
+		return 0
+	}
+	best := -1
+	for i, f := range m.files {
+		if (key == f.name || strings.HasPrefix(key, f.name+"/")) && (best < 0 || len(f.name) > len(m.files[best].name)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// filePrefix returns the key prefix of the sections of file i.
+func (m model) filePrefix(i int) string {
+	if !m.multi {
+		return ""
+	}
+	return m.files[i].name
+}
+
+// cursorFile returns the index of the file the node under the cursor belongs
+// to, or -1 when there is none.
+func (m model) cursorFile() int {
+	if m.cursor >= len(m.nodes) {
+		return -1
+	}
+	return m.fileIndex(m.nodes[m.cursor].key)
+}
+
 // setDefaultCollapsed recursively marks all-completed sections as collapsed.
 func setDefaultCollapsed(sections []TodoSection, prefix string, collapsed map[string]bool) {
 	for _, s := range sections {
@@ -89,17 +263,59 @@ func sectionKey(prefix, heading string) string {
 	return prefix + "/" + heading
 }
 
+// viewOptions controls which nodes flatten produces.
+type viewOptions struct {
+	collapsed map[string]bool
+	match     func(*TodoItem) bool // nil shows every item
+	hideDone  bool                 // drop completed items and fully completed sections
+	byDue     bool                 // list items across sections by their next due date
+}
+
+// keep reports whether an item is shown under these options. An item that
+// does not match itself is still kept to show a matching child.
+func (o viewOptions) keep(item *TodoItem) bool {
+	if o.hideDone && item.closed() {
+		return false
+	}
+	if o.match == nil || o.match(item) {
+		return true
+	}
+	for i := range item.Children {
+		if o.keep(&item.Children[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 // flatten produces a flat list of nodes from the section tree, respecting collapsed state.
-func flatten(sections []TodoSection, collapsed map[string]bool) []node {
+// When opts.match is non-nil only matching items are kept, sections without a
+// matching descendant are dropped, and collapsed sections that contain matches
+// are shown expanded so the matches stay visible. With opts.hideDone completed
+// items and sections whose items are all completed are left out. With
+// opts.byDue the items are listed by due date instead, see flattenByDue.
+func flatten(sections []TodoSection, opts viewOptions) []node {
+	if opts.byDue {
+		return flattenByDue(sections, opts)
+	}
 	var nodes []node
 	for i := range sections {
-		flattenSection(&nodes, &sections[i], 0, "", i%len(pastelColors), collapsed)
+		flattenSection(&nodes, &sections[i], 0, "", i%len(pastelColors), opts)
 	}
 	return nodes
 }
 
 // flattenSection recursively adds nodes for a section and its children.
-func flattenSection(nodes *[]node, s *TodoSection, depth int, prefix string, colorIdx int, collapsed map[string]bool) {
+func flattenSection(nodes *[]node, s *TodoSection, depth int, prefix string, colorIdx int, opts viewOptions) {
+	if opts.match != nil && !sectionHasMatch(s, opts.keep) {
+		return
+	}
+	if opts.hideDone {
+		if done, total := sectionStats(s); total > 0 && done == total {
+			return
+		}
+	}
+
 	key := sectionKey(prefix, s.Heading)
 	*nodes = append(*nodes, node{
 		isSection: true,
@@ -109,38 +325,173 @@ func flattenSection(nodes *[]node, s *TodoSection, depth int, prefix string, col
 		key:       key,
 	})
 
-	if collapsed[key] {
+	if opts.collapsed[key] && opts.match == nil {
 		return
 	}
 
-	for i := range s.Items {
+	flattenItems(nodes, s.Items, depth+1, key, key, colorIdx, opts)
+
+	for i := range s.Subsections {
+		// Sections of a file node get their own colors like top-level sections
+		c := colorIdx
+		if s.Level == 0 {
+			c = i % len(pastelColors)
+		}
+		flattenSection(nodes, &s.Subsections[i], depth+1, key, c, opts)
+	}
+}
+
+// flattenByDue lists the top-level items of every section in one run ordered
+// by their next due date, undated items last, each followed by its children.
+// Items of different sections are interleaved, so no section nodes are shown
+// and section folds do not apply; item folds still do.
+func flattenByDue(sections []TodoSection, opts viewOptions) []node {
+	type entry struct {
+		item     []TodoItem // the item, as a one-element slice of its section's items
+		key      string
+		colorIdx int
+	}
+	var entries []entry
+	var walk func(s *TodoSection, prefix string, colorIdx int)
+	walk = func(s *TodoSection, prefix string, colorIdx int) {
+		if opts.hideDone {
+			if done, total := sectionStats(s); total > 0 && done == total {
+				return
+			}
+		}
+		key := sectionKey(prefix, s.Heading)
+		for i := range s.Items {
+			entries = append(entries, entry{s.Items[i : i+1], key, colorIdx})
+		}
+		for i := range s.Subsections {
+			c := colorIdx
+			if s.Level == 0 {
+				c = i % len(pastelColors)
+			}
+			walk(&s.Subsections[i], key, c)
+		}
+	}
+	for i := range sections {
+		walk(&sections[i], "", i%len(pastelColors))
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return compareDue(&a.item[0], &b.item[0])
+	})
+
+	var nodes []node
+	for _, e := range entries {
+		flattenItems(&nodes, e.item, 0, e.key, e.key, e.colorIdx, opts)
+	}
+	return nodes
+}
+
+// flattenItems adds nodes for items and, unless their parent is collapsed,
+// their children.
+func flattenItems(nodes *[]node, items []TodoItem, depth int, key, parent string, colorIdx int, opts viewOptions) {
+	ordered := make([]*TodoItem, len(items))
+	for i := range items {
+		ordered[i] = &items[i]
+	}
+	if opts.byDue {
+		slices.SortStableFunc(ordered, compareDue)
+	}
+	for _, item := range ordered {
+		if !opts.keep(item) {
+			continue
+		}
+		fold := foldKey(parent, item.Title)
 		*nodes = append(*nodes, node{
 			isSection: false,
-			depth:     depth + 1,
-			item:      &s.Items[i],
+			depth:     depth,
+			item:      item,
 			colorIdx:  colorIdx,
 			key:       key,
+			fold:      fold,
 		})
+		if !opts.collapsed[fold] || opts.match != nil {
+			flattenItems(nodes, item.Children, depth+1, key, fold, colorIdx, opts)
+		}
 	}
+}
 
+// foldKey returns the collapse key of an item with the given title below the
+// section or item with collapse key parent.
+func foldKey(parent, title string) string {
+	return parent + "#" + title
+}
+
+// sectionHasMatch reports whether any item in s or its subsections matches.
+func sectionHasMatch(s *TodoSection, match func(*TodoItem) bool) bool {
+	found := false
+	eachItem(s.Items, func(item *TodoItem) {
+		found = found || match(item)
+	})
+	if found {
+		return true
+	}
 	for i := range s.Subsections {
-		flattenSection(nodes, &s.Subsections[i], depth+1, key, colorIdx, collapsed)
+		if sectionHasMatch(&s.Subsections[i], match) {
+			return true
+		}
 	}
+	return false
 }
 
-// Init starts file watching.
+// Init does nothing; file watching runs for the life of the program and is
+// started by main with StartWatcher.
 func (m model) Init() tea.Cmd {
-	return WatchFile(m.filePath)
+	return nil
 }
 
 // Update handles messages.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.mode != modeNormal {
+			return m.updateInput(msg)
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
 
+		case "/":
+			m.mode = modeSearch
+			m.input = newLineInput(m.query)
+
+		case "t":
+			m.mode = modeTags
+			m.tags = countTags(m.sections)
+			m.tagCursor = min(m.tagCursor, max(len(m.tags)-1, 0))
+
+		case "H":
+			m.hideDone = !m.hideDone
+			m.refreshNodes()
+
+		case "D":
+			m.byDue = !m.byDue
+			m.refreshNodes()
+
+		case "p":
+			m.showDetails = !m.showDetails
+			m.ensureVisible()
+
+		case "n":
+			m.jumpMatch(1)
+
+		case "N":
+			m.jumpMatch(-1)
+
+		case "esc":
+			// Clear the search and tag filters
+			if m.query != "" {
+				m.setQuery("")
+			}
+			if len(m.tagFilter) > 0 {
+				m.tagFilter = make(map[string]bool)
+				m.refreshNodes()
+			}
+
 		case "up", "k":
 			if m.cursor > 0 {
 				m.cursor--
@@ -170,26 +521,33 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ensureVisible()
 
 		case "left", "h":
-			// Collapse: if on a section, collapse it; if on an item, collapse parent section
-			key := m.currentSectionKey()
-			if key != "" {
+			// Collapse: if on an expanded item with children, collapse it;
+			// otherwise collapse the section (or the item's parent section)
+			if n, ok := m.cursorParent(); ok && !m.collapsed[n.fold] {
+				m.collapsed[n.fold] = true
+				m.refreshNodes()
+			} else if key := m.currentSectionKey(); key != "" {
 				m.collapsed[key] = true
-				m.nodes = flatten(m.sections, m.collapsed)
-				m.clampCursor()
-				m.ensureVisible()
+				m.refreshNodes()
 			}
 
 		case "right", "l":
-			// Expand: if on a section, expand it
-			if m.cursor < len(m.nodes) && m.nodes[m.cursor].isSection {
+			// Expand: if on a section or an item with children, expand it
+			if n, ok := m.cursorParent(); ok {
+				delete(m.collapsed, n.fold)
+				m.refreshNodes()
+			} else if m.cursor < len(m.nodes) && m.nodes[m.cursor].isSection {
 				key := m.nodes[m.cursor].key
 				delete(m.collapsed, key)
-				m.nodes = flatten(m.sections, m.collapsed)
-				m.ensureVisible()
+				m.refreshNodes()
 			}
 
+		case "o":
+			return m, m.openEditor()
+
 		case " ", "enter":
-			// Toggle collapse/expand on sections
+			// Toggle collapse/expand on sections; space toggles the checkbox
+			// on items and enter opens them in the editor
 			if m.cursor < len(m.nodes) && m.nodes[m.cursor].isSection {
 				key := m.nodes[m.cursor].key
 				if m.collapsed[key] {
@@ -197,50 +555,120 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					m.collapsed[key] = true
 				}
-				m.nodes = flatten(m.sections, m.collapsed)
-				m.clampCursor()
-				m.ensureVisible()
+				m.refreshNodes()
+			} else if msg.String() == " " {
+				m.toggleItem()
+			} else {
+				return m, m.openEditor()
+			}
+
+		case "x":
+			m.toggleItem()
+
+		case "i":
+			// Edit the title, tags and details of the item under the cursor
+			if fi := m.cursorFile(); fi >= 0 && !m.nodes[m.cursor].isSection {
+				m.startEdit(m.files[fi].path, m.nodes[m.cursor].item)
+			}
+
+		case "K", "shift+up":
+			m.moveCurrent(-1)
+
+		case "J", "shift+down":
+			m.moveCurrent(1)
+
+		case "m":
+			// Pick a section to move the item under the cursor into
+			if fi := m.cursorFile(); fi >= 0 && !m.nodes[m.cursor].isSection {
+				m.mode = modeMove
+				m.editPath = m.files[fi].path
+				m.editLine = m.nodes[m.cursor].item.Line
+				m.editHash = m.files[fi].hash
+				// Items only move within their own file
+				m.targets = collectTargets(m.files[fi].sections, m.filePrefix(fi), 0)
+				m.targetCursor = max(slices.IndexFunc(m.targets, func(t moveTarget) bool {
+					return t.key == m.nodes[m.cursor].key
+				}), 0)
+			}
+
+		case "A":
+			// Archive the completed items of the file under the cursor
+			fi := m.cursorFile()
+			if fi < 0 {
+				break
+			}
+			m.archiveItems(fi)
+
+		case "u":
+			m.undo()
+
+		case "ctrl+r":
+			m.redo()
+
+		case "a":
+			// Add an item to the section under the cursor
+			if key := m.currentSectionKey(); key != "" && !m.nodes[m.cursor].isFile() {
+				m.mode = modeAdd
+				m.input = newLineInput("")
+				m.inputTarget = key
 			}
 
 		case "c":
 			// Collapse all sections
 			m.collapsed = make(map[string]bool)
 			collapseAll(m.sections, "", m.collapsed)
-			m.nodes = flatten(m.sections, m.collapsed)
-			m.clampCursor()
-			m.ensureVisible()
+			m.refreshNodes()
 
 		case "e":
 			// Expand all sections
 			m.collapsed = make(map[string]bool)
-			m.nodes = flatten(m.sections, m.collapsed)
-			m.ensureVisible()
+			m.refreshNodes()
+
+		case ".":
+			// The change under the cursor has been seen; move on to the next
+			m.acknowledgeCursor()
+			m.jumpChange()
 
 		case "r":
-			sections, err := ReadAndParse(m.filePath)
-			if err != nil {
-				m.err = err
-			} else {
-				m.err = nil
-				m.sections = sections
-				m.nodes = flatten(m.sections, m.collapsed)
-				m.clampCursor()
-				m.ensureVisible()
-			}
-			return m, WatchFile(m.filePath)
+			m.err = nil
+			m.reload()
+			cmd := m.flashTick()
+			return m, cmd
+		}
+		if navigationKeys[msg.String()] {
+			m.acknowledgeCursor()
 		}
 
-	case FileUpdatedMsg:
+	case FilesChangedMsg:
+		// Keep a conflict visible until the user acts on it
+		if !errors.Is(m.err, errConflict) {
+			m.err = nil
+		}
+		m.applyFileChanges(msg)
+		cmd := m.flashTick()
+		return m, cmd
+
+	case flashExpiredMsg:
+		m.flashArmed = false
+		m.expireChanges()
+		cmd := m.flashTick()
+		return m, cmd
+
+	case EditorFinishedMsg:
+		if msg.Err != nil {
+			m.err = msg.Err
+			return m, nil
+		}
 		m.err = nil
-		m.sections = msg.Sections
-		m.nodes = flatten(m.sections, m.collapsed)
-		m.clampCursor()
-		m.ensureVisible()
-		return m, WatchFile(m.filePath)
+		m.reload()
+		cmd := m.flashTick()
+		return m, cmd
 
 	case FileErrorMsg:
 		m.err = msg.Err
-		return m, WatchFile(m.filePath)
+
+	case WatchFallbackMsg:
+		m.pollReason = msg.Err
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -251,6 +679,348 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateInput handles keys while the inline prompt is open.
+func (m model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.mode == modeEdit {
+		return m.updateForm(msg)
+	}
+	if m.mode == modeMove {
+		return m.updateMove(msg)
+	}
+	if m.mode == modeSearch {
+		return m.updateSearch(msg)
+	}
+	if m.mode == modeTags {
+		return m.updateTags(msg)
+	}
+
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.mode = modeNormal
+
+	case tea.KeyEnter:
+		mode := m.mode
+		text := strings.TrimSpace(m.input.Value())
+		m.mode = modeNormal
+		if text == "" {
+			break
+		}
+		switch mode {
+		case modeAdd:
+			m.addItem(m.inputTarget, text)
+		}
+
+	default:
+		m.input.Update(msg)
+	}
+	return m, nil
+}
+
+// startEdit opens the edit form pre-filled from item.
+func (m *model) startEdit(path string, item *TodoItem) {
+	m.mode = modeEdit
+	m.editPath = path
+	m.editLine = item.Line
+	m.editHash = m.fileHash(path)
+	m.form = []lineInput{
+		newLineInput(item.Title),
+		newLineInput(strings.Join(item.Tags, " ")),
+	}
+	for _, d := range item.Details {
+		m.form = append(m.form, newLineInput(d))
+	}
+	// Trailing empty field for adding a detail line
+	m.form = append(m.form, newLineInput(""))
+	m.formFocus = formTitle
+	m.ensureVisible()
+}
+
+// updateForm handles keys while the edit form is open.
+func (m model) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.mode = modeNormal
+		m.form = nil
+
+	case tea.KeyTab, tea.KeyDown:
+		m.formFocus = (m.formFocus + 1) % len(m.form)
+
+	case tea.KeyShiftTab, tea.KeyUp:
+		m.formFocus = (m.formFocus + len(m.form) - 1) % len(m.form)
+
+	case tea.KeyEnter:
+		m.mode = modeNormal
+		m.saveEdit()
+		m.form = nil
+
+	default:
+		m.form[m.formFocus].Update(msg)
+		// Typing into the last detail field opens another one below it
+		if m.formFocus == len(m.form)-1 && m.form[m.formFocus].Value() != "" {
+			m.form = append(m.form, newLineInput(""))
+		}
+	}
+	m.ensureVisible()
+	return m, nil
+}
+
+// saveEdit writes the edit form back to the item's source line and details.
+func (m *model) saveEdit() {
+	title := strings.TrimSpace(m.form[formTitle].Value())
+	if title == "" {
+		m.err = errors.New("title cannot be empty")
+		return
+	}
+
+	var tags []string
+	for _, tag := range strings.FieldsFunc(m.form[formTags].Value(), func(r rune) bool {
+		return r == ' ' || r == ','
+	}) {
+		tags = append(tags, strings.Trim(tag, "[]"))
+	}
+
+	var details []string
+	for _, field := range m.form[formFirstDetail:] {
+		if d := strings.TrimSpace(field.Value()); d != "" {
+			details = append(details, d)
+		}
+	}
+
+	if m.editTargetChanged() {
+		return
+	}
+	path, line := m.editPath, m.editLine
+	m.applyEdit(path, func(content string) (string, error) {
+		return editItem(content, line, title, tags, details, m.symbols)
+	})
+	if m.err == nil {
+		m.selectLine(path, line)
+	}
+}
+
+// updateMove handles keys while the move-to-section picker is open.
+func (m model) updateMove(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc", "q":
+		m.mode = modeNormal
+
+	case "up", "k":
+		m.targetCursor = max(m.targetCursor-1, 0)
+
+	case "down", "j":
+		m.targetCursor = min(m.targetCursor+1, len(m.targets)-1)
+
+	case "enter":
+		m.mode = modeNormal
+		if m.targetCursor < len(m.targets) && !m.editTargetChanged() {
+			m.moveItemToSection(m.editPath, m.editLine, m.targets[m.targetCursor].key)
+		}
+	}
+	return m, nil
+}
+
+// fileHash returns the snapshot hash of the file at path, or "" if it is not
+// shown.
+func (m model) fileHash(path string) string {
+	if fi := m.fileByPath(path); fi >= 0 {
+		return m.files[fi].hash
+	}
+	return ""
+}
+
+// editTargetChanged reports a conflict if the file of the item being edited
+// or moved has changed since editLine was taken, as the line may now hold a
+// different item.
+func (m *model) editTargetChanged() bool {
+	if m.fileHash(m.editPath) != m.editHash {
+		m.err = errConflict
+		return true
+	}
+	return false
+}
+
+// collectTargets lists every section in tree order for the move picker.
+func collectTargets(sections []TodoSection, prefix string, depth int) []moveTarget {
+	var targets []moveTarget
+	for _, s := range sections {
+		key := sectionKey(prefix, s.Heading)
+		targets = append(targets, moveTarget{key: key, heading: s.Heading, depth: depth})
+		targets = append(targets, collectTargets(s.Subsections, key, depth+1)...)
+	}
+	return targets
+}
+
+// moveCurrent moves the item or section under the cursor one place up
+// (dir < 0) or down (dir > 0) among its siblings and keeps it selected.
+func (m *model) moveCurrent(dir int) {
+	fi := m.cursorFile()
+	if fi < 0 || m.nodes[m.cursor].isFile() {
+		return
+	}
+	n := m.nodes[m.cursor]
+	path := m.files[fi].path
+
+	newLine := 0
+	if n.isSection {
+		siblings, idx := siblingSections(m.sections, "", n.key)
+		if siblings == nil {
+			return
+		}
+		m.applyEdit(path, func(content string) (string, error) {
+			updated, l, err := moveSection(content, siblings, idx, dir)
+			newLine = l
+			return updated, err
+		})
+	} else {
+		s := findSection(m.sections, "", n.key)
+		if s == nil {
+			return
+		}
+		section, line := *s, n.item.Line
+		m.applyEdit(path, func(content string) (string, error) {
+			updated, l, err := moveItem(content, section, line, dir, m.symbols)
+			newLine = l
+			return updated, err
+		})
+	}
+	if m.err == nil {
+		m.selectLine(path, newLine)
+	}
+}
+
+// moveItemToSection moves the item on line of the file at path to the end of
+// the section with the given key and follows it there.
+func (m *model) moveItemToSection(path string, line int, key string) {
+	s := findSection(m.sections, "", key)
+	if s == nil {
+		m.err = fmt.Errorf("section %q not found", key)
+		return
+	}
+	target := *s
+
+	newLine := 0
+	m.applyEdit(path, func(content string) (string, error) {
+		updated, l, err := moveItemTo(content, line, target, m.symbols)
+		newLine = l
+		return updated, err
+	})
+	if m.err != nil {
+		return
+	}
+
+	delete(m.collapsed, key)
+	m.refreshNodes()
+	m.selectLine(path, newLine)
+}
+
+// siblingSections returns the slice of sections that contains the section with
+// the given key, along with its index in that slice.
+func siblingSections(sections []TodoSection, prefix, key string) ([]TodoSection, int) {
+	for i := range sections {
+		k := sectionKey(prefix, sections[i].Heading)
+		if k == key {
+			return sections, i
+		}
+		if found, idx := siblingSections(sections[i].Subsections, k, key); found != nil {
+			return found, idx
+		}
+	}
+	return nil, -1
+}
+
+// addItem appends a new open item to the section with the given key, saves the
+// file and moves the cursor onto the new item.
+func (m *model) addItem(key, text string) {
+	s := findSection(m.sections, "", key)
+	if s == nil {
+		m.err = fmt.Errorf("section %q not found", key)
+		return
+	}
+	section := *s
+	path := m.files[m.fileIndex(key)].path
+
+	line := 0
+	m.applyEdit(path, func(content string) (string, error) {
+		updated, l, err := insertItem(content, section, text, m.symbols)
+		line = l
+		return updated, err
+	})
+	if m.err != nil {
+		return
+	}
+
+	delete(m.collapsed, key)
+	m.refreshNodes()
+	m.selectLine(path, line)
+}
+
+// findSection returns the section with the given collapse key, or nil.
+func findSection(sections []TodoSection, prefix, key string) *TodoSection {
+	for i := range sections {
+		k := sectionKey(prefix, sections[i].Heading)
+		if k == key {
+			return &sections[i]
+		}
+		if found := findSection(sections[i].Subsections, k, key); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// openEditor opens the file in the external editor at the item under the
+// cursor. The cursor stays anchored to the item when the file is reloaded.
+func (m *model) openEditor() tea.Cmd {
+	fi := m.cursorFile()
+	if fi < 0 || m.nodes[m.cursor].isSection {
+		return nil
+	}
+	return OpenEditor(m.files[fi].path, m.nodes[m.cursor].item.Line)
+}
+
+// selectLine moves the cursor to the visible item or section on the given
+// source line of the file at path.
+func (m *model) selectLine(path string, line int) {
+	fi := m.fileByPath(path)
+	for i, n := range m.nodes {
+		if !n.isFile() && n.line() == line && m.fileIndex(n.key) == fi {
+			m.cursor = i
+			m.ensureVisible()
+			return
+		}
+	}
+}
+
+// refreshNodes rebuilds the visible rows after sections or collapse state change.
+func (m *model) refreshNodes() {
+	m.nodes = flatten(m.sections, viewOptions{
+		collapsed: m.collapsed,
+		match:     m.matcher(),
+		hideDone:  m.hideDone,
+		byDue:     m.byDue,
+	})
+	m.clampCursor()
+	m.ensureVisible()
+}
+
+// cursorParent returns the node under the cursor if it is an item with
+// children.
+func (m model) cursorParent() (node, bool) {
+	if m.cursor >= len(m.nodes) || m.nodes[m.cursor].isSection || len(m.nodes[m.cursor].item.Children) == 0 {
+		return node{}, false
+	}
+	return m.nodes[m.cursor], true
+}
+
 // currentSectionKey returns the collapse key for the current cursor position.
 // If the cursor is on a section, returns that section's key.
 // If the cursor is on an item, returns the parent section's key.
@@ -266,6 +1036,93 @@ func (m model) currentSectionKey() string {
 	return n.key
 }
 
+// toggleItem flips the checkbox of the item under the cursor and saves the file.
+func (m *model) toggleItem() {
+	fi := m.cursorFile()
+	if fi < 0 || m.nodes[m.cursor].isSection {
+		return
+	}
+	line := m.nodes[m.cursor].item.Line
+	m.applyEdit(m.files[fi].path, func(content string) (string, error) {
+		return toggleCheckbox(content, line, m.symbols)
+	})
+}
+
+// applyEdit reads the file at path, rewrites it with fn and saves the result
+// atomically. The model is refreshed from the written content right away; the
+// watcher then sees our own write as an ordinary change and re-parses the same
+// content. If the file on disk no longer matches the snapshot being shown, the
+// edit is refused, the model is reloaded from disk and errConflict is reported.
+// Successful edits are recorded for undo.
+func (m *model) applyEdit(path string, fn func(content string) (string, error)) {
+	if before, after, ok := m.writeEdit(path, fn); ok {
+		m.recordEdit(editRecord{path: path, before: before, after: after})
+	}
+}
+
+// writeEdit performs the read, conflict check, rewrite and save for applyEdit.
+// It returns the content before and after the edit and whether the file was
+// written.
+func (m *model) writeEdit(path string, fn func(content string) (string, error)) (string, string, bool) {
+	fi := m.fileByPath(path)
+	if fi < 0 {
+		m.err = fmt.Errorf("%s is no longer shown", path)
+		return "", "", false
+	}
+	if m.files[fi].missing {
+		m.err = errMissing
+		return "", "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		m.err = err
+		return "", "", false
+	}
+	if hash := contentHash(data); hash != m.files[fi].hash {
+		m.err = errConflict
+		sections := ParseWith(string(data), m.symbols)
+		m.noteChanges(fi, sections)
+		m.setSnapshot(fi, sections, hash)
+		return "", "", false
+	}
+	updated, err := fn(string(data))
+	if err != nil {
+		m.err = err
+		return "", "", false
+	}
+	if updated == string(data) {
+		m.err = nil
+		return "", "", false
+	}
+	if err := writeFileAtomic(path, []byte(updated)); err != nil {
+		m.err = err
+		return "", "", false
+	}
+
+	m.err = nil
+	m.setSnapshot(fi, ParseWith(updated, m.symbols), contentHash([]byte(updated)))
+	return string(data), updated, true
+}
+
+// setSnapshot replaces the sections of file fi with a freshly parsed snapshot.
+// File updates from the watcher, manual refreshes and the TUI's own edits all
+// go through here. Collapse state follows renamed sections and the cursor
+// stays on the same item or section, or its nearest surviving neighbour.
+func (m *model) setSnapshot(fi int, sections []TodoSection, hash string) {
+	anchors := m.cursorAnchors()
+	old := sectionRefs(m.sections, "")
+
+	m.files[fi].sections = sections
+	m.files[fi].hash = hash
+	m.files[fi].missing = false
+	m.compose()
+
+	renames := matchSections(old, sectionRefs(m.sections, ""))
+	m.collapsed = renameKeys(m.collapsed, renames)
+	m.refreshNodes()
+	m.restoreCursor(anchors, renames)
+}
+
 // clampCursor ensures cursor is within valid range.
 func (m *model) clampCursor() {
 	m.cursor = max(min(m.cursor, len(m.nodes)-1), 0)
@@ -273,7 +1130,23 @@ func (m *model) clampCursor() {
 
 // contentHeight returns the number of visible content lines (between header and footer).
 func (m model) contentHeight() int {
-	return max(m.height-2, 1) // header + footer
+	return max(m.height-2-m.bannerHeight()-m.formHeight()-m.bottomPaneHeight(), 1) // header + footer + banner + edit form + detail pane
+}
+
+// bannerHeight returns the number of lines the missing-file banner occupies.
+func (m model) bannerHeight() int {
+	if len(m.missingFiles()) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// formHeight returns the number of lines the edit form occupies above the footer.
+func (m model) formHeight() int {
+	if m.mode != modeEdit {
+		return 0
+	}
+	return len(m.form)
 }
 
 // ensureVisible adjusts scroll so the cursor is within the visible viewport.
@@ -306,58 +1179,191 @@ func (m model) View() string {
 		Width(m.width).
 		Padding(0, 1)
 	headerText := m.fileName
-	if m.err != nil {
+	if m.multi {
+		headerText += fmt.Sprintf(" (%d files)", len(m.files))
+	}
+	if m.query != "" {
+		headerText += "  /" + m.query
+	}
+	if len(m.tagFilter) > 0 {
+		headerText += "  tags: " + m.tagFilterLabel()
+	}
+	if m.matcher() != nil {
+		headerText += fmt.Sprintf(" (%d matches)", m.matchCount())
+	}
+	if m.hideDone {
+		headerText += "  [hiding done]"
+	}
+	if m.byDue {
+		headerText += "  [by due]"
+	}
+	if m.pollReason != nil {
+		headerText += "  [polling: " + m.pollReason.Error() + "]"
+	}
+	if errors.Is(m.err, errConflict) {
+		headerText += "  [conflict: " + m.err.Error() + "]"
+	} else if m.err != nil {
 		headerText += "  [error: " + m.err.Error() + "]"
 	}
 	b.WriteString(headerStyle.Render(headerText))
 	b.WriteString("\n")
 
+	// Banner while files are deleted on disk
+	if missing := m.missingFiles(); len(missing) > 0 {
+		bannerText := " File removed, waiting..."
+		if m.multi {
+			bannerText = " Removed, waiting: " + strings.Join(missing, ", ")
+		}
+		bannerStyle := lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#222222")).
+			Background(lipgloss.Color("#E6D14D")).
+			Width(m.width)
+		b.WriteString(bannerStyle.Render(lipgloss.NewStyle().MaxWidth(m.width).Render(bannerText)))
+		b.WriteString("\n")
+	}
+
 	// Content area
 	ch := m.contentHeight()
-	endIdx := min(m.scroll+ch, len(m.nodes))
-
+	var list strings.Builder
 	linesRendered := 0
-	for i := m.scroll; i < endIdx; i++ {
-		selected := i == m.cursor
-		b.WriteString(m.renderNode(m.nodes[i], selected))
-		b.WriteString("\n")
-		linesRendered++
+	if m.mode == modeMove {
+		linesRendered = m.renderTargets(&list, ch)
+	} else if m.mode == modeTags {
+		linesRendered = m.renderTags(&list, ch)
+	} else {
+		endIdx := min(m.scroll+ch, len(m.nodes))
+		for i := m.scroll; i < endIdx; i++ {
+			selected := i == m.cursor
+			list.WriteString(m.renderNode(m.nodes[i], selected))
+			list.WriteString("\n")
+			linesRendered++
+		}
 	}
 
 	// Fill remaining lines with empty space
 	for linesRendered < ch {
-		b.WriteString("\n")
+		list.WriteString("\n")
 		linesRendered++
 	}
 
+	// Detail pane beside or below the tree
+	if pw := m.sidePaneWidth(); pw > 0 {
+		listBlock := lipgloss.NewStyle().Width(m.listWidth()).Render(strings.TrimSuffix(list.String(), "\n"))
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, listBlock, m.renderPane(pw, ch, true)))
+		b.WriteString("\n")
+	} else {
+		b.WriteString(list.String())
+		if ph := m.bottomPaneHeight(); ph > 0 {
+			b.WriteString(m.renderPane(m.width, ph, false))
+			b.WriteString("\n")
+		}
+	}
+
+	// Edit form
+	if m.mode == modeEdit {
+		b.WriteString(m.renderForm())
+	}
+
 	// Footer
 	done, total := m.countStats()
 	footerLeft := fmt.Sprintf(" %d/%d done", done, total)
-	footerRight := " q:quit  j/k:nav  space:fold  r:refresh "
+	if len(m.changed) > 0 {
+		footerLeft += lipgloss.NewStyle().Foreground(lipgloss.Color(flashColor)).Render(fmt.Sprintf("  %d changed", len(m.changed)))
+	}
+	footerRight := " q:quit  j/k:nav  /:search  t:tags  H:hide done  D:by due  p:details  .:next change  space:fold  x:toggle  o:open  a:add  i:edit  J/K/m:move  A:archive  u:undo  r:refresh "
 	gap := max(m.width-lipgloss.Width(footerLeft)-lipgloss.Width(footerRight), 0)
 	footerText := footerLeft + strings.Repeat(" ", gap) + footerRight
+	if m.mode == modeEdit {
+		footerText = " enter:save  tab:next field  esc:cancel"
+	} else if m.mode == modeMove {
+		footerText = " move to section  j/k:nav  enter:move  esc:cancel"
+	} else if m.mode == modeTags {
+		footerText = " tags  space:select  tab:and/or  c:clear  enter/esc:close"
+	} else if m.mode == modeSearch {
+		footerText = " /" + m.input.View()
+	} else if m.mode != modeNormal {
+		footerText = " " + m.promptLabel() + m.input.View()
+	}
 
 	footerStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#AAAAAA")).
 		Background(lipgloss.Color("#222222")).
 		Width(m.width)
+	// Truncate rather than wrap so the footer always stays on one line
+	footerText = lipgloss.NewStyle().MaxWidth(m.width).Render(footerText)
 	b.WriteString(footerStyle.Render(footerText))
 
 	return b.String()
 }
 
+// promptLabel returns the text shown before the inline input.
+func (m model) promptLabel() string {
+	switch m.mode {
+	case modeAdd:
+		return "add to " + m.inputTarget + ": "
+	}
+	return ""
+}
+
+// renderTargets renders the move-to-section picker into b, scrolled so the
+// picker cursor stays visible, and returns the number of lines written.
+func (m model) renderTargets(b *strings.Builder, height int) int {
+	start := max(m.targetCursor-height+1, 0)
+	end := min(start+height, len(m.targets))
+	w := max(m.listWidth(), 10)
+	for i := start; i < end; i++ {
+		t := m.targets[i]
+		line := strings.Repeat("  ", t.depth) + t.heading
+		if i == m.targetCursor {
+			b.WriteString(lipgloss.NewStyle().Background(lipgloss.Color("#3A3A3A")).MaxWidth(w).Render(">" + line))
+		} else {
+			b.WriteString(lipgloss.NewStyle().MaxWidth(w).Render(" " + line))
+		}
+		b.WriteString("\n")
+	}
+	return end - start
+}
+
+// renderForm renders the edit form fields, one per line.
+func (m model) renderForm() string {
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	var b strings.Builder
+	for i, field := range m.form {
+		label := "detail:"
+		switch i {
+		case formTitle:
+			label = "title: "
+		case formTags:
+			label = "tags:  "
+		}
+		value := field.Value()
+		if i == m.formFocus {
+			value = field.View()
+		}
+		b.WriteString(lipgloss.NewStyle().MaxWidth(max(m.width, 10)).Render(" " + labelStyle.Render(label) + " " + value))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 // renderNode renders a single node line.
 func (m model) renderNode(n node, selected bool) string {
-	w := max(m.width, 10)
+	w := max(m.listWidth(), 10)
 
 	color := pastelColors[n.colorIdx%len(pastelColors)]
+	// Rows of a deleted file are dimmed while waiting for it to reappear
+	missing := m.files[max(m.fileIndex(n.key), 0)].missing
+	if missing {
+		color = lipgloss.Color(missingColor)
+	}
 	indent := strings.Repeat("  ", n.depth)
 
 	var line string
 	if n.isSection {
 		// Section heading with [done/total] badge
 		done, total := sectionStats(n.section)
-		isCollapsed := m.collapsed[n.key]
+		isCollapsed := m.collapsed[n.key] && m.matcher() == nil
 
 		arrow := "▼"
 		if isCollapsed {
@@ -369,6 +1375,9 @@ func (m model) renderNode(n node, selected bool) string {
 		headingStyle := lipgloss.NewStyle().
 			Bold(true).
 			Foreground(color)
+		if n.isFile() && !missing {
+			headingStyle = headingStyle.Foreground(lipgloss.Color("#FFFFFF")).Underline(true)
+		}
 		if n.section.AllCompleted {
 			headingStyle = headingStyle.Strikethrough(true).Faint(true)
 		}
@@ -376,17 +1385,25 @@ func (m model) renderNode(n node, selected bool) string {
 			Foreground(lipgloss.Color("#888888"))
 
 		line = indent + arrow + " " + headingStyle.Render(n.section.Heading) + " " + badgeStyle.Render(badge)
+		if isCollapsed && sectionHasMatch(n.section, m.isChanged) {
+			line += " " + lipgloss.NewStyle().Foreground(lipgloss.Color(flashColor)).Render("●")
+		}
 	} else {
 		// Todo item
-		checkbox := "[ ]"
-		if n.item.Completed {
-			checkbox = "[x]"
-		}
+		// The box shows the file's checkbox; the title only reads as done
+		// once the item's children are done too
+		checkbox := statusGlyphs[n.item.Status]
 
 		titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#DDDDDD"))
-		if n.item.Completed {
+		if missing {
+			titleStyle = titleStyle.Foreground(color)
+		}
+		if n.item.closed() {
 			titleStyle = titleStyle.Strikethrough(true).Faint(true)
 		}
+		if m.isChanged(n.item) && !missing {
+			titleStyle = titleStyle.Bold(true).Foreground(lipgloss.Color(flashColor))
+		}
 
 		tagStr := ""
 		if len(n.item.Tags) > 0 {
@@ -398,8 +1415,33 @@ func (m model) renderNode(n node, selected bool) string {
 			tagStr = " " + tagStyle.Render(strings.Join(tagParts, " "))
 		}
 
+		dueStr := ""
+		if !n.item.Due.IsZero() && !n.item.closed() {
+			now := time.Now()
+			dueStr = " " + lipgloss.NewStyle().Foreground(dueColor(n.item.Due, now)).Render(dueText(n.item.Due, now))
+		}
+
+		// Items with children show their progress, and an arrow when folded
+		childStr := ""
+		if len(n.item.Children) > 0 {
+			if done, total := itemStats(n.item.Children); total > 0 {
+				childStr = " " + lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(fmt.Sprintf("[%d/%d]", done, total))
+			}
+			if m.collapsed[n.fold] && m.matcher() == nil {
+				childStr += " ▶"
+				changed := false
+				eachItem(n.item.Children, func(item *TodoItem) { changed = changed || m.isChanged(item) })
+				if changed {
+					childStr += " " + lipgloss.NewStyle().Foreground(lipgloss.Color(flashColor)).Render("●")
+				}
+			}
+		}
+
 		checkStyle := lipgloss.NewStyle().Foreground(color)
-		line = indent + checkStyle.Render(checkbox) + " " + titleStyle.Render(n.item.Title) + tagStr
+		if c, ok := statusColors[n.item.Status]; ok && !missing {
+			checkStyle = checkStyle.Foreground(c)
+		}
+		line = indent + checkStyle.Render(checkbox) + " " + titleStyle.Render(n.item.Title) + tagStr + dueStr + childStr
 	}
 
 	// Apply selection highlight
@@ -415,21 +1457,32 @@ func (m model) renderNode(n node, selected bool) string {
 	return padStyle.Render(" " + line)
 }
 
-// sectionStats returns (done, total) counts for a section and all its descendants.
+// sectionStats returns (done, total) counts for a section and all its
+// descendants, nested items included.
 func sectionStats(s *TodoSection) (int, int) {
+	done, total := itemStats(s.Items)
+	for i := range s.Subsections {
+		d, t := sectionStats(&s.Subsections[i])
+		done += d
+		total += t
+	}
+	return done, total
+}
+
+// itemStats returns (done, total) counts for items and all their children.
+// Cancelled items count towards neither.
+func itemStats(items []TodoItem) (int, int) {
 	done := 0
 	total := 0
-	for _, item := range s.Items {
+	eachItem(items, func(item *TodoItem) {
+		if item.Status == StatusCancelled {
+			return
+		}
 		total++
 		if item.Completed {
 			done++
 		}
-	}
-	for i := range s.Subsections {
-		d, t := sectionStats(&s.Subsections[i])
-		done += d
-		total += t
-	}
+	})
 	return done, total
 }
 
@@ -2,22 +2,34 @@ package main
 
 import (
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // TodoItem represents a single checkbox item in a markdown file.
+// Status is what its own checkbox says. Completed is true only when that is
+// StatusDone and all of its children are completed or cancelled.
 type TodoItem struct {
 	Title     string
+	Marker    string // list marker as written: "-", "*", "+", "1." or "1)"
+	Status    Status
 	Completed bool
 	Line      int
+	Due       time.Time // zero when the item has no due date
+	Scheduled time.Time // zero when the item is not scheduled
 	Tags      []string
 	Details   []string
+	Code      []bool     // Code[i] reports that Details[i] is a line of code, kept as written
+	Children  []TodoItem // checkboxes indented below this one
 }
 
 // TodoSection represents a heading-delimited section containing items and subsections.
 type TodoSection struct {
 	Heading      string
 	Level        int
+	Line         int
 	Items        []TodoItem
 	Subsections  []TodoSection
 	AllCompleted bool
@@ -26,6 +38,16 @@ type TodoSection struct {
 // tagRegex matches tags like [ssmd] or [api/v2] in checkbox text.
 var tagRegex = regexp.MustCompile(`\[([a-zA-Z][a-zA-Z0-9/]*)\]`)
 
+// checkboxRegex matches the list marker and checkbox that start a task list
+// item: a "-", "*" or "+" bullet or an ordered list number, then "[c] ".
+var checkboxRegex = regexp.MustCompile(`^([-*+]|[0-9]{1,9}[.)]) \[(.)\] `)
+
+// listItemRegex matches the marker that starts any list item, task or not.
+var listItemRegex = regexp.MustCompile(`^([-*+]|[0-9]{1,9}[.)])(\s|$)`)
+
+// fenceRegex matches the opening fence of a fenced code block.
+var fenceRegex = regexp.MustCompile("^(`{3,}|~{3,})")
+
 // detailPrefixRegex strips a leading "- " from detail lines.
 var detailPrefixRegex = regexp.MustCompile(`^- `)
 
@@ -33,26 +55,83 @@ var detailPrefixRegex = regexp.MustCompile(`^- `)
 type stackEntry struct {
 	level       int
 	heading     string
+	line        int
 	items       []TodoItem
 	subsections []TodoSection
+	path        []int // indexes from items down to the most recent item
+	indents     []int // indentation of each item along path
+}
+
+// addItem adds item to the entry as a child of the closest preceding open item
+// that is indented less, or as a direct item of the section.
+func (e *stackEntry) addItem(item TodoItem, indent int) {
+	for len(e.indents) > 0 && e.indents[len(e.indents)-1] >= indent {
+		e.indents = e.indents[:len(e.indents)-1]
+		e.path = e.path[:len(e.path)-1]
+	}
+	siblings := &e.items
+	for _, i := range e.path {
+		siblings = &(*siblings)[i].Children
+	}
+	*siblings = append(*siblings, item)
+	e.path = append(e.path, len(*siblings)-1)
+	e.indents = append(e.indents, indent)
+}
+
+// lastItem returns the most recently added item, or nil if there is none.
+func (e *stackEntry) lastItem() *TodoItem {
+	var item *TodoItem
+	items := e.items
+	for _, i := range e.path {
+		item = &items[i]
+		items = item.Children
+	}
+	return item
 }
 
 // Parse parses markdown content and returns a slice of top-level TodoSections.
 // It recognizes headings at levels 2-4 (## through ####), ignoring h1 (#).
-// Checkboxes are extracted from lines matching "- [ ]" or "- [x]"/"- [X]".
+// Checkboxes are extracted from task list lines like "- [c]", "* [c]", "+ [c]"
+// or "1. [c]" where c is one of the default status symbols, such as " ",
+// "x", "/" or "-".
+// A checkbox indented deeper than the one before it becomes its child.
+// Headings and checkboxes inside fenced or indented code are kept as detail
+// text as written, and HTML comments are skipped entirely, including comments
+// that open or close partway through a line.
 func Parse(content string) []TodoSection {
+	return ParseWith(content, nil)
+}
+
+// ParseWith is Parse with checkbox symbols mapped to statuses by symbols, or by
+// defaultStatusSymbols when symbols is nil.
+func ParseWith(content string, symbols map[byte]Status) []TodoSection {
 	lines := strings.Split(content, "\n")
 	var rootSections []TodoSection
 	var stack []stackEntry
-	var pendingDetails []string
+	var pendingDetails details
+	blocks := newBlockState()
+	inCode := false
+	codeIndent := "" // indentation of the line that opened the current code block
 
 	for index, rawLine := range lines {
 		line := strings.TrimSpace(rawLine)
 		lineNumber := index + 1
+		kind := blocks.next(rawLine)
+		code := kind == blockCode
+		if !code {
+			line = stripComments(line)
+		}
+		if code && !inCode {
+			codeIndent = leadingSpace(rawLine)
+		}
+		inCode = code
+		if kind == blockComment {
+			continue
+		}
 
-		if level, heading, ok := parseHeading(line); ok {
+		if level, heading, ok := parseHeading(line); ok && !code {
 			// Flush pending details to last item
-			flushDetails(&pendingDetails, &stack)
+			pendingDetails.flush(&stack)
 
 			// Pop stack entries with level >= current heading level
 			for len(stack) > 0 && stack[len(stack)-1].level >= level {
@@ -65,22 +144,26 @@ func Parse(content string) []TodoSection {
 					stack[len(stack)-1].subsections = append(stack[len(stack)-1].subsections, section)
 				}
 			}
-			stack = append(stack, stackEntry{level: level, heading: heading})
-		} else if item, ok := parseCheckbox(line, lineNumber); ok {
+			stack = append(stack, stackEntry{level: level, heading: heading, line: lineNumber})
+		} else if item, ok := parseCheckbox(line, lineNumber, symbols); ok && !code {
 			// Flush pending details to previous item before starting a new one
-			flushDetails(&pendingDetails, &stack)
+			pendingDetails.flush(&stack)
 			if len(stack) > 0 {
-				stack[len(stack)-1].items = append(stack[len(stack)-1].items, item)
+				stack[len(stack)-1].addItem(item, indentWidth(rawLine))
 			}
 		} else if line != "" && len(stack) > 0 && len(stack[len(stack)-1].items) > 0 {
-			// Non-empty line after a checkbox — collect as detail
-			detail := detailPrefixRegex.ReplaceAllString(strings.TrimSpace(rawLine), "")
-			pendingDetails = append(pendingDetails, detail)
+			// Non-empty line after a checkbox — collect as detail. Code is kept
+			// as written, less the indentation of its block.
+			detail := detailPrefixRegex.ReplaceAllString(line, "")
+			if code {
+				detail = strings.TrimSuffix(strings.TrimPrefix(rawLine, codeIndent), "\r")
+			}
+			pendingDetails.add(detail, code)
 		}
 	}
 
 	// Flush any remaining pending details
-	flushDetails(&pendingDetails, &stack)
+	pendingDetails.flush(&stack)
 
 	// Pop remaining stack entries
 	for len(stack) > 0 {
@@ -97,6 +180,111 @@ func Parse(content string) []TodoSection {
 	return rootSections
 }
 
+// blockKind says what part a line plays in the block structure of a document.
+type blockKind int
+
+const (
+	blockText    blockKind = iota // ordinary markdown: headings, checkboxes and details
+	blockCode                     // fenced or indented code, fence lines included
+	blockComment                  // an HTML comment
+)
+
+// blockState follows fenced code, indented code and HTML comments through a
+// document line by line, so that headings and checkboxes inside them are not
+// taken for real ones.
+type blockState struct {
+	fence   string // fence that opened the current fenced code block
+	comment bool   // inside an HTML comment
+	code    bool   // inside an indented code block
+	blank   bool   // the previous line was blank
+	list    bool   // the last text line belonged to a list, so indented lines continue it instead of starting code
+}
+
+// newBlockState returns the state at the start of a document, or at a heading
+// or item line known to be outside any block.
+func newBlockState() blockState {
+	return blockState{blank: true}
+}
+
+// next returns the kind of rawLine, the line following the ones seen so far.
+func (b *blockState) next(rawLine string) blockKind {
+	line := strings.TrimSpace(rawLine)
+	blank := b.blank
+	b.blank = line == ""
+
+	switch {
+	case b.fence != "":
+		if strings.HasPrefix(line, b.fence) && strings.Trim(line, b.fence[:1]) == "" {
+			b.fence = ""
+		}
+		return blockCode
+	case b.comment:
+		end := strings.Index(line, "-->")
+		b.comment = end < 0 || opensComment(line[end+len("-->"):])
+		return blockComment
+	case b.code && (line == "" || indentWidth(rawLine) >= 4):
+		return blockCode
+	case line != "" && blank && !b.list && indentWidth(rawLine) >= 4:
+		b.code = true
+		return blockCode
+	}
+	b.code = false
+
+	switch {
+	case line == "":
+	case fenceRegex.MatchString(line):
+		b.fence = fenceRegex.FindString(line)
+		return blockCode
+	case strings.Contains(line, "<!--"):
+		b.comment = opensComment(line)
+		if stripComments(line) == "" {
+			return blockComment
+		}
+		b.list = listItemRegex.MatchString(line) || b.list && indentWidth(rawLine) > 0
+	default:
+		b.list = listItemRegex.MatchString(line) || b.list && indentWidth(rawLine) > 0
+	}
+	return blockText
+}
+
+// opensComment reports whether line leaves an HTML comment open at its end.
+func opensComment(line string) bool {
+	for {
+		start := strings.Index(line, "<!--")
+		if start < 0 {
+			return false
+		}
+		line = line[start+len("<!--"):]
+		end := strings.Index(line, "-->")
+		if end < 0 {
+			return true
+		}
+		line = line[end+len("-->"):]
+	}
+}
+
+// stripComments removes the HTML comments in line, including one left open at
+// its end, and trims the result. Text on both sides of a comment is joined by a
+// single space.
+func stripComments(line string) string {
+	var parts []string
+	for {
+		start := strings.Index(line, "<!--")
+		if start < 0 {
+			parts = append(parts, strings.TrimSpace(line))
+			break
+		}
+		parts = append(parts, strings.TrimSpace(line[:start]))
+		line = line[start+len("<!--"):]
+		end := strings.Index(line, "-->")
+		if end < 0 {
+			break
+		}
+		line = line[end+len("-->"):]
+	}
+	return strings.Join(slices.DeleteFunc(parts, func(p string) bool { return p == "" }), " ")
+}
+
 // parseHeading checks if a line is a heading of level 2-4.
 // Returns (level, heading text, true) or (0, "", false).
 func parseHeading(line string) (int, string, bool) {
@@ -118,37 +306,76 @@ func parseHeading(line string) (int, string, bool) {
 	return level, heading, true
 }
 
-// parseCheckbox checks if a line is a checkbox item.
+// parseCheckbox checks if a line is a checkbox item, mapping its symbol to a
+// status by symbols, or by defaultStatusSymbols when symbols is nil.
 // Returns (TodoItem, true) if it matches, or (TodoItem{}, false) otherwise.
-func parseCheckbox(line string, lineNumber int) (TodoItem, bool) {
+func parseCheckbox(line string, lineNumber int, symbols map[byte]Status) (TodoItem, bool) {
 	trimmed := strings.TrimSpace(line)
-	var completed bool
-	var rest string
-
-	if strings.HasPrefix(trimmed, "- [ ] ") {
-		completed = false
-		rest = trimmed[6:]
-	} else if strings.HasPrefix(trimmed, "- [x] ") || strings.HasPrefix(trimmed, "- [X] ") {
-		completed = true
-		rest = trimmed[6:]
-	} else {
+	match := checkboxRegex.FindStringSubmatch(trimmed)
+	if match == nil || len(match[2]) != 1 {
+		return TodoItem{}, false
+	}
+	if symbols == nil {
+		symbols = defaultStatusSymbols
+	}
+	status, ok := symbols[match[2][0]]
+	if !ok {
 		return TodoItem{}, false
 	}
+	rest := stripComments(trimmed[len(match[0]):])
 
 	title := extractTitle(rest)
 	tags := extractTags(rest)
+	due, scheduled := extractDates(rest)
 
 	return TodoItem{
 		Title:     title,
-		Completed: completed,
+		Marker:    match[1],
+		Status:    status,
+		Completed: status == StatusDone,
 		Line:      lineNumber,
+		Due:       due,
+		Scheduled: scheduled,
 		Tags:      tags,
 	}, true
 }
 
+// checkboxWidth returns the length of the marker and checkbox that start the
+// item's line after its indentation, such as "- [ ] " or "1. [x] ".
+func (item *TodoItem) checkboxWidth() int {
+	return len(item.Marker) + len(" [ ] ")
+}
+
+// nextMarker returns the list marker for an item added after one with marker:
+// the same bullet, or the next number of an ordered list.
+func nextMarker(marker string) string {
+	n, err := strconv.Atoi(marker[:len(marker)-1])
+	if err != nil {
+		return marker
+	}
+	return strconv.Itoa(n+1) + marker[len(marker)-1:]
+}
+
+// indentWidth returns the width of the line's leading whitespace, counting a
+// tab as four columns.
+func indentWidth(line string) int {
+	width := 0
+	for _, ch := range line {
+		switch ch {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
 // extractTitle extracts the display title from checkbox text.
 // If the text contains bold markers **title**, the bold content is used.
-// Otherwise, text before " [" or " - " is used.
+// Otherwise, text before " [", " - " or a date token is used.
 func extractTitle(text string) string {
 	boldStart := strings.Index(text, "**")
 	if boldStart >= 0 {
@@ -166,6 +393,9 @@ func extractTitle(text string) string {
 	if idx := strings.Index(title, " - "); idx >= 0 {
 		title = title[:idx]
 	}
+	if idx := dateTokenStart(title); idx >= 0 {
+		title = title[:idx]
+	}
 	return strings.TrimSpace(title)
 }
 
@@ -186,18 +416,40 @@ func extractTags(text string) []string {
 
 // buildSection creates a TodoSection from a stack entry, computing AllCompleted.
 func buildSection(entry stackEntry) TodoSection {
+	settleCompleted(entry.items)
 	allItems := collectAllItems(entry.items, entry.subsections)
 	allCompleted := len(allItems) == 0 || allItemsCompleted(allItems)
 
 	return TodoSection{
 		Heading:      entry.heading,
 		Level:        entry.level,
+		Line:         entry.line,
 		Items:        entry.items,
 		Subsections:  entry.subsections,
 		AllCompleted: allCompleted,
 	}
 }
 
+// settleCompleted marks items with open children as not completed, so a
+// parent only reads as done once everything below it is done or cancelled.
+func settleCompleted(items []TodoItem) {
+	for i := range items {
+		settleCompleted(items[i].Children)
+		if items[i].Completed && !allItemsCompleted(items[i].Children) {
+			items[i].Completed = false
+		}
+	}
+}
+
+// eachItem calls fn for every item in items and their descendants, in
+// document order.
+func eachItem(items []TodoItem, fn func(*TodoItem)) {
+	for i := range items {
+		fn(&items[i])
+		eachItem(items[i].Children, fn)
+	}
+}
+
 // collectAllItems gathers all items from direct items and all subsections recursively.
 func collectAllItems(items []TodoItem, subsections []TodoSection) []TodoItem {
 	all := make([]TodoItem, 0, len(items))
@@ -208,28 +460,35 @@ func collectAllItems(items []TodoItem, subsections []TodoSection) []TodoItem {
 	return all
 }
 
-// allItemsCompleted returns true if every item in the slice is completed.
+// allItemsCompleted returns true if every item in the slice is completed or
+// cancelled.
 func allItemsCompleted(items []TodoItem) bool {
 	for _, item := range items {
-		if !item.Completed {
+		if !item.closed() {
 			return false
 		}
 	}
 	return true
 }
 
-// flushDetails attaches accumulated detail lines to the last item on the stack.
-func flushDetails(details *[]string, stack *[]stackEntry) {
-	if len(*details) == 0 || len(*stack) == 0 {
-		*details = nil
-		return
-	}
-	idx := len(*stack) - 1
-	if len((*stack)[idx].items) == 0 {
-		*details = nil
-		return
+// details collects the detail lines of the item being parsed.
+type details struct {
+	lines []string
+	code  []bool // whether each line is code
+}
+
+// add appends a detail line.
+func (d *details) add(line string, code bool) {
+	d.lines = append(d.lines, line)
+	d.code = append(d.code, code)
+}
+
+// flush attaches the collected lines to the last item on the stack.
+func (d *details) flush(stack *[]stackEntry) {
+	if len(d.lines) > 0 && len(*stack) > 0 {
+		if item := (*stack)[len(*stack)-1].lastItem(); item != nil {
+			item.Details, item.Code = d.lines, d.code
+		}
 	}
-	lastIdx := len((*stack)[idx].items) - 1
-	(*stack)[idx].items[lastIdx].Details = *details
-	*details = nil
+	*d = details{}
 }
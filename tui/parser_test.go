@@ -1,6 +1,7 @@
 package main
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -370,3 +371,185 @@ func TestItemCountAcrossNesting(t *testing.T) {
 		t.Errorf("expected 5 total items, got %d", total)
 	}
 }
+
+func TestParseSectionLineNumbers(t *testing.T) {
+	markdown := "# Title\n## First\n- [ ] Task\n### Nested\n## Second"
+	sections := Parse(markdown)
+
+	if len(sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(sections))
+	}
+	if sections[0].Line != 2 {
+		t.Errorf("expected First on line 2, got %d", sections[0].Line)
+	}
+	if sections[0].Subsections[0].Line != 4 {
+		t.Errorf("expected Nested on line 4, got %d", sections[0].Subsections[0].Line)
+	}
+	if sections[1].Line != 5 {
+		t.Errorf("expected Second on line 5, got %d", sections[1].Line)
+	}
+}
+
+func TestParseNestedItems(t *testing.T) {
+	markdown := `## Plan
+- [ ] Parent
+  parent detail
+  - [x] Child one
+    - [x] Grandchild
+  - [ ] Child two
+    child detail
+- [x] Done parent
+	- [x] Tabbed child
+- [x] Checked parent
+  - [ ] Open child`
+	sections := Parse(markdown)
+	items := sections[0].Items
+
+	if len(items) != 3 {
+		t.Fatalf("expected 3 top-level items, got %d", len(items))
+	}
+	parent := items[0]
+	if len(parent.Children) != 2 || len(parent.Children[0].Children) != 1 {
+		t.Fatalf("unexpected nesting: %+v", parent)
+	}
+	if got := parent.Details; len(got) != 1 || got[0] != "parent detail" {
+		t.Errorf("parent details = %v", got)
+	}
+	if got := parent.Children[1].Details; len(got) != 1 || got[0] != "child detail" {
+		t.Errorf("child details = %v", got)
+	}
+	if parent.Children[0].Children[0].Line != 5 {
+		t.Errorf("grandchild line = %d, want 5", parent.Children[0].Children[0].Line)
+	}
+	if len(items[1].Children) != 1 || !items[1].Completed {
+		t.Errorf("expected tab-indented child under a completed parent, got %+v", items[1])
+	}
+
+	checked := items[2]
+	if checked.Status != StatusDone || checked.Completed {
+		t.Error("a checked parent with an open child should not read as completed")
+	}
+	if sections[0].AllCompleted {
+		t.Error("section with open nested items should not be all completed")
+	}
+	if done, total := sectionStats(&sections[0]); done != 4 || total != 8 {
+		t.Errorf("stats = %d/%d, want 4/8", done, total)
+	}
+}
+
+func TestParseListMarkers(t *testing.T) {
+	markdown := "## Tasks\n* [ ] Star\n+ [x] Plus\n1. [ ] First\n12) [/] Twelfth\n   * [ ] Nested star\n*[ ] No space\n1.[ ] No space\na. [ ] Letter"
+	items := Parse(markdown)[0].Items
+
+	want := []struct{ title, marker string }{
+		{"Star", "*"}, {"Plus", "+"}, {"First", "1."}, {"Twelfth", "12)"},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("expected %d items, got %d: %+v", len(want), len(items), items)
+	}
+	for i, w := range want {
+		if items[i].Title != w.title || items[i].Marker != w.marker {
+			t.Errorf("item %d = %q with marker %q, want %q with %q", i, items[i].Title, items[i].Marker, w.title, w.marker)
+		}
+	}
+	if !items[1].Completed || items[3].Status != StatusInProgress {
+		t.Error("checkbox state should be read after any marker")
+	}
+	if len(items[3].Children) != 1 || items[3].Children[0].Marker != "*" {
+		t.Errorf("expected a nested star item under the ordered item, got %+v", items[3].Children)
+	}
+}
+
+func TestParseIgnoresFencedCode(t *testing.T) {
+	markdown := "## Tasks\n- [ ] Write script\n  ```sh\n  ## not a heading\n  - [ ] not a task\n  ```\n- [ ] After\n~~~~\n- [ ] tilde fence\n~~~\nstill code\n~~~~\n## Next\n- [ ] Real"
+	sections := Parse(markdown)
+
+	if len(sections) != 2 || sections[1].Heading != "Next" {
+		t.Fatalf("expected sections Tasks and Next, got %+v", sections)
+	}
+	items := sections[0].Items
+	if len(items) != 2 || items[1].Title != "After" {
+		t.Fatalf("expected 2 items, got %+v", items)
+	}
+	want := []string{"```sh", "## not a heading", "- [ ] not a task", "```"}
+	if got := items[0].Details; !slices.Equal(got, want) {
+		t.Errorf("fenced lines should become details as written, got %q", got)
+	}
+	if len(items[1].Details) != 5 {
+		t.Errorf("a shorter fence should not close a longer one, details %q", items[1].Details)
+	}
+}
+
+func TestParseIgnoresIndentedCode(t *testing.T) {
+	markdown := "## Notes\nExample file:\n\n    ## Heading\n    - [ ] Example task\n\n    more code\n- [ ] Real\n\n    continued detail\n    - [ ] Nested"
+	sections := Parse(markdown)
+
+	if len(sections) != 1 {
+		t.Fatalf("expected 1 section, got %d", len(sections))
+	}
+	items := sections[0].Items
+	if len(items) != 1 || items[0].Title != "Real" {
+		t.Fatalf("expected only the real item, got %+v", items)
+	}
+	if len(items[0].Children) != 1 || items[0].Children[0].Title != "Nested" {
+		t.Errorf("indented lines inside a list should not be code, got %+v", items[0])
+	}
+	if len(items[0].Details) != 1 || items[0].Details[0] != "continued detail" {
+		t.Errorf("details = %q", items[0].Details)
+	}
+}
+
+func TestParseSkipsHTMLComments(t *testing.T) {
+	markdown := "## Tasks\n- [ ] Visible\n<!-- - [ ] inline comment -->\n<!--\n## Hidden\n- [ ] Hidden task\n-->\n  detail\n- [ ] Also visible <!-- trailing note -->"
+	sections := Parse(markdown)
+
+	if len(sections) != 1 {
+		t.Fatalf("expected 1 section, got %d", len(sections))
+	}
+	items := sections[0].Items
+	if len(items) != 2 || items[1].Line != 9 {
+		t.Fatalf("expected 2 items, got %+v", items)
+	}
+	if got := items[0].Details; len(got) != 1 || got[0] != "detail" {
+		t.Errorf("comment lines should not become details, got %q", got)
+	}
+	if items[1].Title != "Also visible" {
+		t.Errorf("trailing comment should not be part of the title, got %q", items[1].Title)
+	}
+}
+
+func TestParseSkipsCommentsOpenedMidLine(t *testing.T) {
+	markdown := "## Tasks\n- [ ] Real [a] <!-- [b] note -->\n  intro <!-- start\n## Hidden\n- [ ] hidden\n-->\n  after <!-- one --> and <!-- two\n  still hidden -->\n- [ ] Next"
+	sections := Parse(markdown)
+
+	if len(sections) != 1 {
+		t.Fatalf("expected 1 section, got %+v", sections)
+	}
+	items := sections[0].Items
+	if len(items) != 2 || items[1].Title != "Next" {
+		t.Fatalf("expected Real and Next, got %+v", items)
+	}
+	if items[0].Title != "Real" || !slices.Equal(items[0].Tags, []string{"a"}) {
+		t.Errorf("comment should not count in title or tags, got %q %q", items[0].Title, items[0].Tags)
+	}
+	if want := []string{"intro", "after and"}; !slices.Equal(items[0].Details, want) {
+		t.Errorf("details = %q, want %q", items[0].Details, want)
+	}
+}
+
+func TestParseIgnoresBlockquotes(t *testing.T) {
+	markdown := "## Tasks\n- [ ] Real\n  > ## quoted heading\n  > - [ ] quoted task\n> - [x] top-level quote\n- [ ] Next"
+	sections := Parse(markdown)
+
+	if len(sections) != 1 {
+		t.Fatalf("expected 1 section, got %+v", sections)
+	}
+	items := sections[0].Items
+	if len(items) != 2 || items[1].Title != "Next" || len(items[0].Children) != 0 {
+		t.Fatalf("quoted lines should not be tasks, got %+v", items)
+	}
+	want := []string{"> ## quoted heading", "> - [ ] quoted task", "> - [x] top-level quote"}
+	if !slices.Equal(items[0].Details, want) {
+		t.Errorf("details = %q, want %q", items[0].Details, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// defaultPollInterval is how often files are polled when file system
+// notifications are unavailable and no interval was given.
+const defaultPollInterval = time.Second
+
+// fileStat is what polling compares to notice that a file changed.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// poller detects changes to the files of a set of sources by comparing them
+// against what it saw on the previous scan, for file systems such as NFS or
+// sshfs where notifications never arrive.
+type poller struct {
+	src   sources
+	known map[string]fileStat
+}
+
+// newPoller returns a poller whose first scan only reports changes made after
+// it was created.
+func newPoller(src sources) *poller {
+	p := &poller{src: src, known: make(map[string]fileStat)}
+	p.scan()
+	return p
+}
+
+// scan returns the files that appeared, disappeared or changed content since
+// the previous scan. The content is only hashed when the modification time or
+// size differ, and a file whose hash is unchanged is not reported.
+func (p *poller) scan() []string {
+	paths, err := p.src.expand()
+	if err != nil {
+		return nil
+	}
+
+	var changed []string
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+		prev, ok := p.known[path]
+		if ok && info.ModTime().Equal(prev.modTime) && info.Size() == prev.size {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		stat := fileStat{modTime: info.ModTime(), size: info.Size(), hash: contentHash(data)}
+		p.known[path] = stat
+		if !ok || stat.hash != prev.hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range p.known {
+		if !seen[path] {
+			delete(p.known, path)
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
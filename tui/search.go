@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// matchesQuery reports whether the item's title, tags or details contain
+// query, ignoring case. query must already be lower-cased.
+func matchesQuery(item *TodoItem, query string) bool {
+	if strings.Contains(strings.ToLower(item.Title), query) {
+		return true
+	}
+	for _, tag := range item.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return true
+		}
+	}
+	for _, d := range item.Details {
+		if strings.Contains(strings.ToLower(d), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateSearch handles keys while the search prompt is open. The filter is
+// applied as the query is typed.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.mode = modeNormal
+		m.setQuery("")
+
+	case tea.KeyEnter:
+		m.mode = modeNormal
+
+	default:
+		m.input.Update(msg)
+		m.setQuery(strings.TrimSpace(m.input.Value()))
+	}
+	return m, nil
+}
+
+// setQuery changes the search filter and moves the cursor to the first match.
+func (m *model) setQuery(query string) {
+	if query == m.query {
+		return
+	}
+	m.query = query
+	m.refreshNodes()
+	if query != "" {
+		m.cursor = -1
+		m.jumpMatch(1)
+		m.clampCursor()
+	}
+}
+
+// jumpMatch moves the cursor to the next (dir > 0) or previous (dir < 0)
+// matching item, wrapping around the ends of the list. Parent items shown only
+// for a matching child are skipped.
+func (m *model) jumpMatch(dir int) {
+	match := m.matcher()
+	if match == nil || len(m.nodes) == 0 {
+		return
+	}
+	for step := 1; step <= len(m.nodes); step++ {
+		i := ((m.cursor+dir*step)%len(m.nodes) + len(m.nodes)) % len(m.nodes)
+		if !m.nodes[i].isSection && match(m.nodes[i].item) {
+			m.cursor = i
+			m.ensureVisible()
+			return
+		}
+	}
+}
+
+// matchCount returns the number of items matching the active filters.
+func (m model) matchCount() int {
+	match := m.matcher()
+	count := 0
+	for _, n := range m.nodes {
+		if !n.isSection && (match == nil || match(n.item)) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSearchKeepsCollapsedAncestors(t *testing.T) {
+	m := newTestModel(t, "## Work\n### Active\n- [ ] Fix connector timeout [ssmd]\n- [ ] Update docs\n## Done\n- [x] Old task\n  mentions timeout\n")
+	m.collapsed["Work"] = true
+	m.refreshNodes()
+
+	m.setQuery("TIMEOUT")
+
+	var got []string
+	for _, n := range m.nodes {
+		if n.isSection {
+			got = append(got, "#"+n.key)
+		} else {
+			got = append(got, n.item.Title)
+		}
+	}
+	want := []string{"#Work", "#Work/Active", "Fix connector timeout", "#Done", "Old task"}
+	if len(got) != len(want) {
+		t.Fatalf("nodes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("node %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if m.cursor != 2 {
+		t.Errorf("cursor = %d, want 2 (first match)", m.cursor)
+	}
+
+	m.jumpMatch(1)
+	if m.cursor != 4 {
+		t.Errorf("cursor after n = %d, want 4", m.cursor)
+	}
+	m.jumpMatch(1)
+	if m.cursor != 2 {
+		t.Errorf("cursor after wrapping n = %d, want 2", m.cursor)
+	}
+	m.jumpMatch(-1)
+	if m.cursor != 4 {
+		t.Errorf("cursor after N = %d, want 4", m.cursor)
+	}
+
+	m.setQuery("ssmd")
+	if m.matchCount() != 1 {
+		t.Errorf("expected tag match, got %d matches", m.matchCount())
+	}
+
+	m.setQuery("")
+	if len(m.nodes) != 2 {
+		t.Errorf("expected collapsed Work and Done again after clearing, got %d nodes", len(m.nodes))
+	}
+}
+
+func TestNestedItemsFoldAndMatch(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] Parent\n  - [ ] Needle\n  - [ ] Other\n")
+	if len(m.nodes) != 4 {
+		t.Fatalf("expected children shown by default, got %d nodes", len(m.nodes))
+	}
+
+	m.cursor = 1
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	m = updated.(model)
+	if len(m.nodes) != 2 || cursorTitle(m) != "Parent" {
+		t.Fatalf("expected children folded under Parent, got %d nodes on %q", len(m.nodes), cursorTitle(m))
+	}
+
+	// Searching reveals a folded match along with its parent
+	m.setQuery("needle")
+	if len(m.nodes) != 3 || cursorTitle(m) != "Needle" {
+		t.Errorf("expected section, parent and match with the cursor on the match, got %d nodes on %q", len(m.nodes), cursorTitle(m))
+	}
+	if m.matchCount() != 1 {
+		t.Errorf("matchCount = %d, want 1", m.matchCount())
+	}
+}
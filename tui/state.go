@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// uiState is the per-file UI state persisted between TUI sessions.
+type uiState struct {
+	Path      string       `json:"path"`
+	Collapsed []string     `json:"collapsed"`
+	Cursor    *anchorState `json:"cursor,omitempty"`
+	Scroll    int          `json:"scroll"`
+	Query     string       `json:"query,omitempty"`
+	Tags      []string     `json:"tags,omitempty"`
+	TagsAll   bool         `json:"tagsAll,omitempty"`
+	HideDone  bool         `json:"hideDone,omitempty"`
+	ByDue     bool         `json:"byDue,omitempty"`
+}
+
+// anchorState is the JSON form of an anchor.
+type anchorState struct {
+	Section    bool   `json:"section,omitempty"`
+	Path       string `json:"path"`
+	Title      string `json:"title,omitempty"`
+	Occurrence int    `json:"occurrence,omitempty"`
+}
+
+// stateDir returns the directory UI state is kept in:
+// $XDG_STATE_HOME/todoagent, defaulting to ~/.local/state/todoagent.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "todoagent"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "todoagent"), nil
+}
+
+// statePath returns the state file for the markdown file at absPath. The name
+// is derived from a hash of the path so any path maps to a safe file name.
+func statePath(absPath string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, contentHash([]byte(absPath))[:16]+".json"), nil
+}
+
+// loadState reads the saved UI state for absPath.
+func loadState(absPath string) (uiState, error) {
+	var st uiState
+	path, err := statePath(absPath)
+	if err != nil {
+		return st, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// saveState writes the model's UI state for its file.
+func (m model) saveState() error {
+	path, err := statePath(m.filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m.uiState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// uiState captures the state worth restoring next session.
+func (m model) uiState() uiState {
+	st := uiState{
+		Path:     m.filePath,
+		Scroll:   m.scroll,
+		Query:    m.query,
+		Tags:     m.selectedTags(),
+		TagsAll:  m.tagsAll,
+		HideDone: m.hideDone,
+		ByDue:    m.byDue,
+	}
+	for key, collapsed := range m.collapsed {
+		if collapsed {
+			st.Collapsed = append(st.Collapsed, key)
+		}
+	}
+	slices.Sort(st.Collapsed)
+	if anchors := m.cursorAnchors(); len(anchors) > 0 {
+		a := anchors[0]
+		st.Cursor = &anchorState{Section: a.isSection, Path: a.path, Title: a.title, Occurrence: a.occurrence}
+	}
+	return st
+}
+
+// applyState restores saved UI state onto a freshly created model.
+func (m *model) applyState(st uiState) {
+	m.collapsed = make(map[string]bool, len(st.Collapsed))
+	for _, key := range st.Collapsed {
+		m.collapsed[key] = true
+	}
+	m.query = st.Query
+	m.tagFilter = make(map[string]bool, len(st.Tags))
+	for _, tag := range st.Tags {
+		m.tagFilter[tag] = true
+	}
+	m.tagsAll = st.TagsAll
+	m.hideDone = st.HideDone
+	m.byDue = st.ByDue
+	m.refreshNodes()
+
+	if st.Cursor != nil {
+		a := anchor{isSection: st.Cursor.Section, path: st.Cursor.Path, title: st.Cursor.Title, occurrence: st.Cursor.Occurrence}
+		m.restoreCursor([]anchor{a}, nil)
+	}
+	m.scroll = max(min(st.Scroll, m.cursor), 0)
+}
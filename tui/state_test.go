@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUIStateRoundTrip(t *testing.T) {
+	m := newTestModel(t, "## Work\n- [ ] A [api]\n- [ ] B [api]\n## Done\n- [x] C\n")
+	delete(m.collapsed, "Done")
+	m.collapsed["Work"] = false
+	m.tagFilter["api"] = true
+	m.hideDone = true
+	m.refreshNodes()
+	m.cursor = 2 // B
+	if err := m.saveState(); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected state dir under XDG_STATE_HOME: %v", err)
+	}
+
+	sections, hash, err := ReadAndParse(m.filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := initialModel(m.filePath, filepath.Base(m.filePath), sections, hash)
+
+	if restored.collapsed["Done"] {
+		t.Error("expected Done to stay expanded as saved")
+	}
+	if !restored.tagFilter["api"] || !restored.hideDone {
+		t.Errorf("filters not restored: tags %v hideDone %v", restored.tagFilter, restored.hideDone)
+	}
+	if got := cursorTitle(restored); got != "B" {
+		t.Errorf("cursor on %q, want B", got)
+	}
+}
+
+func TestUIStateIgnoredForOtherFiles(t *testing.T) {
+	content := "## Work\n- [ ] A [api]\n## Done\n- [x] C\n"
+	a := newTestModel(t, content)
+	delete(a.collapsed, "Done")
+	a.tagFilter["api"] = true
+	a.hideDone = true
+	if err := a.saveState(); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	// A second file with the same content in the same state directory
+	bPath := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(bPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sections, hash, err := ReadAndParse(bPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := initialModel(bPath, "todo.md", sections, hash)
+
+	if !b.collapsed["Done"] {
+		t.Error("expected default collapsed state for the other file")
+	}
+	if len(b.tagFilter) != 0 || b.hideDone {
+		t.Errorf("other file picked up filters: tags %v hideDone %v", b.tagFilter, b.hideDone)
+	}
+
+	// A state file that names a different path is not applied either
+	aState, err := statePath(a.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bState, err := statePath(bPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(aState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bState, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b = initialModel(bPath, "todo.md", sections, hash)
+	if b.hideDone || !b.collapsed["Done"] {
+		t.Error("state saved for another path should be ignored")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Status is the state written in an item's checkbox.
+type Status int
+
+const (
+	StatusOpen Status = iota
+	StatusDone
+	StatusInProgress
+	StatusCancelled
+	StatusDeferred
+)
+
+// statusNames are the names statuses go by in --status-symbols and the
+// detail pane.
+var statusNames = map[Status]string{
+	StatusOpen:       "open",
+	StatusDone:       "done",
+	StatusInProgress: "in-progress",
+	StatusCancelled:  "cancelled",
+	StatusDeferred:   "deferred",
+}
+
+// String returns the status name.
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+// defaultStatusSymbols maps checkbox symbols to statuses, as in "- [/] task".
+var defaultStatusSymbols = map[byte]Status{
+	' ': StatusOpen,
+	'x': StatusDone,
+	'X': StatusDone,
+	'/': StatusInProgress,
+	'~': StatusInProgress,
+	'-': StatusCancelled,
+	'>': StatusDeferred,
+}
+
+// statusGlyphs are what the tree shows for each status.
+var statusGlyphs = map[Status]string{
+	StatusOpen:       "[ ]",
+	StatusDone:       "[x]",
+	StatusInProgress: "[◐]",
+	StatusCancelled:  "[✗]",
+	StatusDeferred:   "[»]",
+}
+
+// statusColors override the section colour of the checkbox for statuses that
+// need to stand out.
+var statusColors = map[Status]lipgloss.Color{
+	StatusInProgress: lipgloss.Color("#E6D14D"),
+	StatusCancelled:  lipgloss.Color("#777777"),
+	StatusDeferred:   lipgloss.Color("#9973E6"),
+}
+
+// symbolsFlag registers -status-symbols on flags, storing the parsed mapping in
+// symbols. The mapping stays nil, meaning the defaults, unless the flag is
+// given.
+func symbolsFlag(flags *flag.FlagSet, symbols *map[byte]Status) {
+	flags.Func("status-symbols", "extra checkbox symbols as symbol=status pairs, e.g. \"?=deferred,!=in-progress\"", func(spec string) error {
+		parsed, err := parseStatusSymbols(spec)
+		*symbols = parsed
+		return err
+	})
+}
+
+// parseStatusSymbols returns the default symbol mapping extended with spec, a
+// comma-separated list of symbol=status pairs such as "?=deferred,!=in-progress".
+// Symbols must be single printable ASCII characters other than "]".
+func parseStatusSymbols(spec string) (map[byte]Status, error) {
+	symbols := make(map[byte]Status, len(defaultStatusSymbols))
+	for sym, status := range defaultStatusSymbols {
+		symbols[sym] = status
+	}
+	if strings.TrimSpace(spec) == "" {
+		return symbols, nil
+	}
+
+	byName := make(map[string]Status, len(statusNames))
+	for status, name := range statusNames {
+		byName[name] = status
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		sym, name, ok := strings.Cut(pair, "=")
+		if !ok || len(sym) != 1 || sym[0] < ' ' || sym[0] > '~' || sym == "]" {
+			return nil, fmt.Errorf("bad status symbol %q, want a single character followed by =status", pair)
+		}
+		status, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown status %q in %q", name, pair)
+		}
+		symbols[sym[0]] = status
+	}
+	return symbols, nil
+}
+
+// closed reports whether an item needs no more work: it is completed or
+// cancelled.
+func (item *TodoItem) closed() bool {
+	return item.Completed || item.Status == StatusCancelled
+}
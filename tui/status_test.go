@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStatuses(t *testing.T) {
+	markdown := "## Tasks\n- [ ] Open\n- [x] Done\n- [/] Started\n- [~] Also started\n- [-] Dropped\n- [>] Later\n- [?] Unknown"
+	items := Parse(markdown)[0].Items
+
+	want := []Status{StatusOpen, StatusDone, StatusInProgress, StatusInProgress, StatusCancelled, StatusDeferred}
+	if len(items) != len(want) {
+		t.Fatalf("expected %d items, got %d", len(want), len(items))
+	}
+	for i, status := range want {
+		if items[i].Status != status {
+			t.Errorf("item %q: status = %v, want %v", items[i].Title, items[i].Status, status)
+		}
+		if items[i].Completed != (status == StatusDone) {
+			t.Errorf("item %q: completed = %v", items[i].Title, items[i].Completed)
+		}
+	}
+}
+
+func TestCancelledItemsExcludedFromStats(t *testing.T) {
+	markdown := "## Tasks\n- [x] Done\n- [-] Dropped\n- [/] Started\n## Wrapped up\n- [x] Done\n- [-] Dropped\n- [x] Parent\n  - [-] Dropped child"
+	sections := Parse(markdown)
+
+	if done, total := sectionStats(&sections[0]); done != 1 || total != 2 {
+		t.Errorf("stats = %d/%d, want 1/2", done, total)
+	}
+	if !sections[1].AllCompleted {
+		t.Error("section with only done and cancelled items should be all completed")
+	}
+	if !sections[1].Items[2].Completed {
+		t.Error("a cancelled child should not keep its parent open")
+	}
+	if done, total := sectionStats(&sections[1]); done != 2 || total != 2 {
+		t.Errorf("stats = %d/%d, want 2/2", done, total)
+	}
+}
+
+func TestParseStatusSymbols(t *testing.T) {
+	symbols, err := parseStatusSymbols("?=deferred,!=in-progress")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if symbols['?'] != StatusDeferred || symbols['!'] != StatusInProgress {
+		t.Errorf("custom symbols not mapped: %v", symbols)
+	}
+	if symbols['/'] != StatusInProgress || symbols['x'] != StatusDone {
+		t.Error("default symbols should be kept")
+	}
+	if _, ok := defaultStatusSymbols['?']; ok {
+		t.Error("defaults should not be modified")
+	}
+
+	for _, spec := range []string{"?", "??=done", "]=done", "?=finished"} {
+		if _, err := parseStatusSymbols(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCustomSymbolsArePassedThrough(t *testing.T) {
+	symbols, err := parseStatusSymbols("?=deferred,v=done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "## A\n- [?] Later\n  - [v] Checked\n- [ ] Next\n"
+	if items := Parse(content)[0].Items; len(items) != 1 {
+		t.Fatalf("default symbols should not know [?], got %d items", len(items))
+	}
+	items := ParseWith(content, symbols)[0].Items
+	if len(items) != 2 || items[0].Status != StatusDeferred || items[0].Children[0].Status != StatusDone {
+		t.Fatalf("custom symbols not parsed: %+v", items)
+	}
+
+	// Edits see the same items as the parser
+	if end := itemBlockEnd(strings.Split(content, "\n"), 2, symbols); end != 3 {
+		t.Errorf("item block ends at %d, want 3", end)
+	}
+	got, err := toggleCheckbox(content, 3, symbols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## A\n- [?] Later\n  - [ ] Checked\n- [ ] Next\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
@@ -2,87 +2,279 @@
 package main
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/fsnotify/fsnotify"
 )
 
-// FileUpdatedMsg is sent when the watched file changes.
+// FileUpdatedMsg reports the new content of a watched file.
 type FileUpdatedMsg struct {
+	Path     string
 	Sections []TodoSection
+	Hash     string
 }
 
-// FileErrorMsg is sent when there's an error reading the file.
+// FileErrorMsg is sent when there's an error reading a file or watching. Path
+// is empty for errors of the watcher itself.
 type FileErrorMsg struct {
+	Path string
+	Err  error
+}
+
+// WatchFallbackMsg is sent when file notifications can't be set up and the
+// watcher polls for changes instead. Err says why notifications failed.
+type WatchFallbackMsg struct {
 	Err error
 }
 
+// FilesChangedMsg carries a FileUpdatedMsg or FileErrorMsg for every file that
+// changed or appeared within one debounce window.
+type FilesChangedMsg []tea.Msg
+
 // ReadAndParse reads a file and parses it into sections.
-func ReadAndParse(path string) ([]TodoSection, error) {
+// It also returns the content hash of the bytes that were parsed, so writers
+// can detect whether the file changed on disk since this snapshot.
+func ReadAndParse(path string, symbols map[byte]Status) ([]TodoSection, string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return Parse(string(data)), nil
+	return ParseWith(string(data), symbols), contentHash(data), nil
 }
 
-// WatchFile returns a tea.Cmd that watches a file for changes and sends
-// FileUpdatedMsg or FileErrorMsg when the file is modified.
-// The watcher is closed after delivering a single message to avoid leaking
-// goroutines and file descriptors. The caller should re-invoke WatchFile
-// to continue watching.
-func WatchFile(path string) tea.Cmd {
-	return func() tea.Msg {
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			return FileErrorMsg{Err: err}
+// contentHash returns a hex-encoded SHA-256 of data.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// defaultDebounce is how long the watcher waits by default for a burst of
+// changes to settle.
+const defaultDebounce = 100 * time.Millisecond
+
+// watchOptions controls how the watcher detects and delivers changes.
+type watchOptions struct {
+	poll     time.Duration   // poll at this interval instead of using notifications
+	debounce time.Duration   // wait this long for a burst of changes to settle
+	symbols  map[byte]Status // checkbox symbols to parse with; nil means the defaults
+}
+
+// Watcher watches the files of a set of sources for the lifetime of the
+// program and delivers a FilesChangedMsg for each settled burst of changes.
+// It uses file system notifications, or polls where those are unavailable.
+type Watcher struct {
+	fs       *fsnotify.Watcher // nil when polling
+	poll     *poller           // nil unless polling
+	src      sources
+	debounce time.Duration
+	symbols  map[byte]Status
+	send     func(tea.Msg)
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartWatcher starts watching the files of src and delivers messages through
+// send, typically tea.Program.Send, until Close is called. Every re-read file
+// is delivered with its content hash; the model skips content it already
+// shows, such as its own writes coming back. Directories are watched rather
+// than files: atomic saves replace the file with a rename, and
+// new files only show up as events on their directory. Directories are watched
+// recursively so new .md files anywhere below them are picked up, as are new
+// files matching a pattern.
+//
+// With a positive opts.poll the files are polled at that interval instead.
+// Polling is also used, at defaultPollInterval, when file system
+// notifications cannot be set up; a WatchFallbackMsg then tells the user.
+func StartWatcher(src sources, send func(tea.Msg), opts watchOptions) *Watcher {
+	w := &Watcher{
+		src:      src,
+		debounce: cmp.Or(opts.debounce, defaultDebounce),
+		symbols:  opts.symbols,
+		send:     send,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	pollInterval := opts.poll
+	var fallback error
+	if pollInterval <= 0 {
+		fw, err := notifyWatcher(src)
+		if err == nil {
+			w.fs = fw
+			go w.run(nil)
+			return w
+		}
+		pollInterval = defaultPollInterval
+		fallback = err
+	}
+
+	w.poll = newPoller(src)
+	ticker := time.NewTicker(pollInterval)
+	go func() {
+		defer ticker.Stop()
+		if fallback != nil {
+			w.send(WatchFallbackMsg{Err: fallback})
+		}
+		w.run(ticker.C)
+	}()
+	return w
+}
+
+// notifyWatcher returns an fsnotify watcher on every directory src needs.
+func notifyWatcher(src sources) (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := src.watchDirs()
+	if err != nil {
+		fw.Close()
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, err
 		}
+	}
+	return fw, nil
+}
+
+// Close stops watching and waits for the watcher goroutine to exit.
+func (w *Watcher) Close() error {
+	close(w.stop)
+	var err error
+	if w.fs != nil {
+		err = w.fs.Close()
+	}
+	<-w.done
+	return err
+}
 
-		if err := watcher.Add(path); err != nil {
-			watcher.Close()
-			return FileErrorMsg{Err: err}
+// run collects changed paths, from notifications or from a poll on every
+// tick, until the debounce timer fires, then re-reads and sends them.
+func (w *Watcher) run(tick <-chan time.Time) {
+	defer close(w.done)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if w.fs != nil {
+		events, errs = w.fs.Events, w.fs.Errors
+	}
+
+	dirty := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		var paths []string
+		select {
+		case <-w.stop:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			paths = w.changedPaths(event)
+		case <-tick:
+			paths = w.poll.scan()
+		case <-timer.C:
+			if msgs := readChanged(slices.Sorted(maps.Keys(dirty)), w.symbols); len(msgs) > 0 {
+				w.send(msgs)
+			}
+			clear(dirty)
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			w.send(FileErrorMsg{Err: err})
+		}
+		if len(paths) == 0 {
+			continue
 		}
+		for _, path := range paths {
+			dirty[path] = true
+		}
+		timer.Reset(w.debounce)
+	}
+}
 
-		done := make(chan tea.Msg, 1)
-
-		go func() {
-			defer watcher.Close()
-
-			var timer *time.Timer
-			for {
-				select {
-				case event, ok := <-watcher.Events:
-					if !ok {
-						return
-					}
-					if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-						if timer != nil {
-							timer.Stop()
-						}
-						timer = time.AfterFunc(100*time.Millisecond, func() {
-							sections, err := ReadAndParse(path)
-							if err != nil {
-								done <- FileErrorMsg{Err: err}
-							} else {
-								done <- FileUpdatedMsg{Sections: sections}
-							}
-						})
-					}
-				case err, ok := <-watcher.Errors:
-					if !ok {
-						return
-					}
-					done <- FileErrorMsg{Err: err}
-					return
-				}
+// changedPaths returns the files an event affects. A directory created inside
+// a recursively watched one, or matching the directory part of a pattern, is
+// added to the watcher, and the files already in it count as changed since
+// they appeared before it was watched.
+func (w *Watcher) changedPaths(event fsnotify.Event) []string {
+	// Remove and Rename fire when a file is replaced by an atomic save (ours
+	// included); the debounced re-read picks up the new file like any other
+	// write.
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return nil
+	}
+	path := filepath.Clean(event.Name)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if event.Op&fsnotify.Create == 0 {
+			return nil
+		}
+		if w.src.globDir(path) {
+			return w.watchGlobDir(path)
+		}
+		if !w.src.recursive(path) {
+			return nil
+		}
+		sub := sources{dirs: []string{path}}
+		dirs, err := sub.watchDirs()
+		if err != nil {
+			w.send(FileErrorMsg{Err: err})
+			return nil
+		}
+		for _, dir := range dirs {
+			if err := w.fs.Add(dir); err != nil {
+				w.send(FileErrorMsg{Err: err})
 			}
-		}()
+		}
+		paths, _ := sub.expand()
+		return paths
+	}
+	if w.src.matches(path) {
+		return []string{path}
+	}
+	return nil
+}
 
-		msg := <-done
-		// Closing the watcher stops the goroutine via the !ok paths
-		watcher.Close()
-		return msg
+// watchGlobDir adds the watches for the patterns of the sources after a
+// directory that can hold their matches was created at path, and returns the
+// matching files already in it.
+func (w *Watcher) watchGlobDir(path string) []string {
+	globs := sources{globs: w.src.globs}
+	dirs, _ := globs.watchDirs()
+	for _, dir := range dirs {
+		if err := w.fs.Add(dir); err != nil {
+			w.send(FileErrorMsg{Err: err})
+		}
+	}
+	paths, _ := globs.expand()
+	return slices.DeleteFunc(paths, func(p string) bool {
+		rel, err := filepath.Rel(path, p)
+		return err != nil || !filepath.IsLocal(rel)
+	})
+}
+
+// readChanged re-reads the given files, parsing them with symbols.
+func readChanged(paths []string, symbols map[byte]Status) FilesChangedMsg {
+	msgs := make(FilesChangedMsg, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			msgs = append(msgs, FileErrorMsg{Path: path, Err: err})
+			continue
+		}
+		msgs = append(msgs, FileUpdatedMsg{Path: path, Sections: ParseWith(string(data), symbols), Hash: contentHash(data)})
 	}
+	return msgs
 }
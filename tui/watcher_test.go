@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func startTestWatcher(t *testing.T, src sources, pollInterval time.Duration) <-chan tea.Msg {
+	t.Helper()
+	msgs := make(chan tea.Msg, 10)
+	w := StartWatcher(src, func(msg tea.Msg) { msgs <- msg }, watchOptions{poll: pollInterval})
+	t.Cleanup(func() { w.Close() })
+	return msgs
+}
+
+func nextUpdate(t *testing.T, msgs <-chan tea.Msg) FileUpdatedMsg {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		changed, ok := msg.(FilesChangedMsg)
+		if !ok || len(changed) != 1 {
+			t.Fatalf("unexpected message %#v", msg)
+		}
+		update, ok := changed[0].(FileUpdatedMsg)
+		if !ok {
+			t.Fatalf("unexpected change %#v", changed[0])
+		}
+		return update
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	return FileUpdatedMsg{}
+}
+
+func TestWatcherFollowsAtomicSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(path, []byte("## A\n- [ ] one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msgs := startTestWatcher(t, sources{files: []string{path}}, 0)
+
+	// Each save replaces the file by rename; the watcher must keep delivering
+	for _, content := range []string{"## A\n- [x] one\n", "## A\n- [ ] one\n- [ ] two\n"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		update := nextUpdate(t, msgs)
+		if update.Path != path || update.Hash != contentHash([]byte(content)) {
+			t.Errorf("unexpected update %+v", update)
+		}
+	}
+}
+
+func TestWatcherPicksUpNewFilesInNewDirectories(t *testing.T) {
+	dir := t.TempDir()
+	msgs := startTestWatcher(t, sources{dirs: []string{dir}}, 0)
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sub", "new.md")
+	if err := os.WriteFile(path, []byte("## New\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(t, msgs); update.Path != path {
+		t.Errorf("got update for %s, want %s", update.Path, path)
+	}
+}
+
+func TestWatcherFollowsPatternWithWildcardDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(dir, "a", "todo.md")
+	if err := os.WriteFile(existing, []byte("## A\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msgs := startTestWatcher(t, sources{globs: []string{filepath.Join(dir, "*", "todo.md")}}, 0)
+
+	// Notifications work, so a change arrives without falling back to polling
+	if err := writeFileAtomic(existing, []byte("## A\n- [ ] one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(t, msgs); update.Path != existing {
+		t.Errorf("got update for %s, want %s", update.Path, existing)
+	}
+
+	// A new project directory is watched as soon as it appears
+	if err := os.Mkdir(filepath.Join(dir, "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "b", "todo.md")
+	if err := os.WriteFile(path, []byte("## B\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(t, msgs); update.Path != path {
+		t.Errorf("got update for %s, want %s", update.Path, path)
+	}
+}
+
+func TestWatcherPolling(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.md")
+	if err := os.WriteFile(path, []byte("## A\n- [ ] one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	msgs := startTestWatcher(t, sources{dirs: []string{dir}}, 10*time.Millisecond)
+
+	// Same size, different content
+	if err := writeFileAtomic(path, []byte("## A\n- [x] one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(t, msgs); update.Hash != contentHash([]byte("## A\n- [x] one\n")) {
+		t.Errorf("unexpected update %+v", update)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgs:
+		changed, ok := msg.(FilesChangedMsg)
+		if !ok || len(changed) != 1 {
+			t.Fatalf("unexpected message %#v", msg)
+		}
+		if fe, ok := changed[0].(FileErrorMsg); !ok || fe.Path != path {
+			t.Errorf("expected an error for the removed file, got %#v", changed[0])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the removal")
+	}
+}
+
+func TestWatcherReportsPollingFallback(t *testing.T) {
+	// A directory that can't be watched makes notifications fail
+	missing := filepath.Join(t.TempDir(), "missing")
+	msgs := startTestWatcher(t, sources{dirs: []string{missing}}, 0)
+	select {
+	case msg := <-msgs:
+		if fallback, ok := msg.(WatchFallbackMsg); !ok || fallback.Err == nil {
+			t.Fatalf("expected a fallback message, got %#v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the fallback message")
+	}
+
+	m := newTestModel(t, "## A\n- [ ] one\n")
+	m.width, m.height = 200, 20
+	updated, _ := m.Update(WatchFallbackMsg{Err: errors.New("too many open files")})
+	if view := updated.(model).View(); !strings.Contains(view, "[polling: too many open files]") {
+		t.Errorf("header does not show polling:\n%s", view)
+	}
+}
+
+func TestPollerIgnoresTouchWithoutContentChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.md")
+	if err := os.WriteFile(path, []byte("## A\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := newPoller(sources{files: []string{path}})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed := p.scan(); len(changed) != 0 {
+		t.Errorf("expected no change for a touched file, got %v", changed)
+	}
+}
+
+func TestUnchangedContentIgnoredAfterRestore(t *testing.T) {
+	original := "## A\n- [ ] one\n"
+	m := newTestModel(t, original)
+	msgs := make(chan tea.Msg, 10)
+	w := StartWatcher(sources{files: []string{m.filePath}}, func(msg tea.Msg) { msgs <- msg }, watchOptions{debounce: 20 * time.Millisecond})
+	t.Cleanup(func() { w.Close() })
+	apply := func() {
+		t.Helper()
+		select {
+		case msg := <-msgs:
+			updated, _ := m.Update(msg)
+			m = updated.(model)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the watcher")
+		}
+	}
+
+	// Our own write comes back with the content already shown
+	m.cursor = 1
+	m.toggleItem()
+	apply()
+	if len(m.changed) != 0 {
+		t.Errorf("own write flagged as a change: %v", m.changed)
+	}
+
+	// Another tool restoring the earlier content is still picked up
+	if err := writeFileAtomic(m.filePath, []byte(original)); err != nil {
+		t.Fatal(err)
+	}
+	apply()
+	if m.files[0].hash != contentHash([]byte(original)) || m.sections[0].Items[0].Completed {
+		t.Error("expected the restored content to be shown")
+	}
+}